@@ -2,6 +2,8 @@ package main
 
 import (
 	"log"
+	"os"
+	"purge/internal/cli"
 	"purge/internal/tui"
 
 	tea "github.com/charmbracelet/bubbletea"
@@ -9,7 +11,11 @@ import (
 
 func main() {
 
-	p := tea.NewProgram(tui.LoginModel(), tea.WithMouseCellMotion())
+	if len(os.Args) > 1 {
+		os.Exit(cli.Run(os.Args[1:], os.Stdout, os.Stderr))
+	}
+
+	p := tea.NewProgram(tui.New(), tea.WithMouseCellMotion())
 	if _, err := p.Run(); err != nil {
 		log.Fatal("Error running tui:", err)
 	}
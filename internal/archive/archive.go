@@ -0,0 +1,120 @@
+// Package archive keeps a local copy of messages before they are purged.
+package archive
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"purge/internal/discord"
+)
+
+type Format string
+
+const (
+	JSONL Format = "jsonl"
+)
+
+// Writer stores messages. Purger calls Write before deleting a message and won't
+// delete it if Write fails.
+type Writer interface {
+	Write(ctx context.Context, m discord.Message) error
+	io.Closer
+}
+
+// Record is what gets archived for each message.
+type Record struct {
+	ID          string               `json:"id"`
+	ChannelID   string               `json:"channel_id"`
+	Timestamp   string               `json:"timestamp"`
+	Author      discord.Author       `json:"author"`
+	Content     string               `json:"content"`
+	Attachments []discord.Attachment `json:"attachments"`
+}
+
+func NewRecord(m discord.Message) Record {
+	attachments := m.Attachments
+	if attachments == nil {
+		attachments = []discord.Attachment{}
+	}
+	return Record{
+		ID:          m.ID,
+		ChannelID:   m.ChannelID,
+		Timestamp:   m.Timestamp,
+		Author:      m.Author,
+		Content:     m.Content,
+		Attachments: attachments,
+	}
+}
+
+// JSONLWriter writes one JSON Record per line.
+type JSONLWriter struct {
+	w   io.Writer
+	enc *json.Encoder
+}
+
+// NewJSONLWriter writes to w. Close closes w if it is an io.Closer.
+func NewJSONLWriter(w io.Writer) *JSONLWriter {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	return &JSONLWriter{w: w, enc: enc}
+}
+
+func (a *JSONLWriter) Write(ctx context.Context, m discord.Message) error {
+	return a.enc.Encode(NewRecord(m))
+}
+
+func (a *JSONLWriter) Close() error {
+	if c, ok := a.w.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// DefaultDir is where archives go unless told otherwise, e.g. ~/.config/wipecord/archive.
+func DefaultDir() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "wipecord", "archive"), nil
+}
+
+// Path returns the archive file called name, e.g. <dir>/<channelID>.jsonl for a channel.
+// Purges covering several channels share one file named by GuildName or QueueName.
+func Path(dir, name string, format Format) string {
+	return filepath.Join(dir, name+"."+string(format))
+}
+
+// GuildName names the archive of a purge of every channel of a server, "guild-<ID>".
+func GuildName(guildID string) string {
+	return "guild-" + guildID
+}
+
+// QueueName names the archive of a queue of channels purged one after another,
+// "queue-<time>" with the time the queue started.
+func QueueName(start time.Time) string {
+	return "queue-" + start.Format("20060102-150405")
+}
+
+// OpenFile opens path for appending, so repeated or resumed purges of a channel add
+// to the same archive. The file is only readable by the current user.
+func OpenFile(path string, format Format) (Writer, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, err
+	}
+
+	switch format {
+	case JSONL, "":
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+		if err != nil {
+			return nil, err
+		}
+		return NewJSONLWriter(f), nil
+	}
+	return nil, fmt.Errorf("unknown archive format %q", format)
+}
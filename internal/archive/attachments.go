@@ -0,0 +1,183 @@
+package archive
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"purge/internal/discord"
+)
+
+// ManifestEntry records one attachment the Downloader handled.
+type ManifestEntry struct {
+	MessageID    string    `json:"message_id"`
+	ChannelID    string    `json:"channel_id"`
+	AttachmentID string    `json:"attachment_id"`
+	Filename     string    `json:"filename"`
+	ContentType  string    `json:"content_type,omitempty"`
+	URL          string    `json:"url"`
+	Size         int64     `json:"size"`
+	SHA256       string    `json:"sha256,omitempty"`
+	Path         string    `json:"path,omitempty"` // Relative to the downloader's directory.
+	Skipped      string    `json:"skipped,omitempty"`
+	RecordedAt   time.Time `json:"recorded_at"`
+}
+
+// Downloader is a Writer that saves a message's attachments into
+// <dir>/<channel ID>/ and appends an entry per attachment to <dir>/manifest.jsonl.
+type Downloader struct {
+	dir      string
+	maxSize  int64
+	manifest *os.File
+	enc      *json.Encoder
+
+	// HTTP fetches the attachments. The CDN doesn't need the account token, so
+	// this is deliberately not the discord.Client's HTTP client.
+	HTTP *http.Client
+}
+
+// NewDownloader downloads into dir. Attachments bigger than maxSize bytes are
+// recorded in the manifest but not downloaded; 0 means no limit.
+func NewDownloader(dir string, maxSize int64) (*Downloader, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(filepath.Join(dir, "manifest.jsonl"), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	enc := json.NewEncoder(f)
+	enc.SetEscapeHTML(false)
+
+	return &Downloader{
+		dir:      dir,
+		maxSize:  maxSize,
+		manifest: f,
+		enc:      enc,
+		HTTP:     &http.Client{Timeout: 5 * time.Minute},
+	}, nil
+}
+
+// DefaultAttachmentDir is the attachments folder inside DefaultDir.
+func DefaultAttachmentDir() (string, error) {
+	dir, err := DefaultDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "attachments"), nil
+}
+
+var errTooLarge = errors.New("too large")
+
+// Write downloads every attachment of m. Any failure other than the size cap is
+// returned, so the purger keeps the message rather than losing the file.
+func (d *Downloader) Write(ctx context.Context, m discord.Message) error {
+	for _, a := range m.Attachments {
+		entry := ManifestEntry{
+			MessageID:    m.ID,
+			ChannelID:    m.ChannelID,
+			AttachmentID: a.ID,
+			Filename:     a.Filename,
+			ContentType:  a.ContentType,
+			URL:          a.URL,
+			Size:         a.Size,
+		}
+
+		if d.maxSize > 0 && a.Size > d.maxSize {
+			entry.Skipped = fmt.Sprintf("larger than %d bytes", d.maxSize)
+		} else {
+			rel := filepath.Join(m.ChannelID, fmt.Sprintf("%s-%s-%s", m.ID, a.ID, safeFilename(a.Filename)))
+			size, sum, err := d.download(ctx, a.URL, filepath.Join(d.dir, rel))
+			switch {
+			case errors.Is(err, errTooLarge):
+				entry.Skipped = fmt.Sprintf("larger than %d bytes", d.maxSize)
+			case err != nil:
+				return fmt.Errorf("downloading attachment %s: %w", a.Filename, err)
+			default:
+				entry.Path, entry.Size, entry.SHA256 = rel, size, sum
+			}
+		}
+
+		entry.RecordedAt = time.Now().UTC()
+		if err := d.enc.Encode(entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// download streams url to path via a temporary file, returning its size and SHA-256.
+func (d *Downloader) download(ctx context.Context, url, path string) (int64, string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return 0, "", err
+	}
+	resp, err := d.HTTP.Do(req)
+	if err != nil {
+		return 0, "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, "", fmt.Errorf("status %s", resp.Status)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return 0, "", err
+	}
+	tmp := path + ".part"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return 0, "", err
+	}
+	defer os.Remove(tmp)
+
+	body := io.Reader(resp.Body)
+	if d.maxSize > 0 {
+		// The size in the message can be missing, so the cap is enforced while reading too.
+		body = io.LimitReader(resp.Body, d.maxSize+1)
+	}
+
+	h := sha256.New()
+	n, err := io.Copy(io.MultiWriter(f, h), body)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return 0, "", err
+	}
+	if d.maxSize > 0 && n > d.maxSize {
+		return 0, "", errTooLarge
+	}
+
+	if err := os.Rename(tmp, path); err != nil {
+		return 0, "", err
+	}
+	return n, hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func (d *Downloader) Close() error {
+	return d.manifest.Close()
+}
+
+// safeFilename keeps attachment names from escaping the archive directory.
+func safeFilename(name string) string {
+	name = filepath.Base(strings.ReplaceAll(name, "\\", "/"))
+	if name == "." || name == "/" || name == ".." || name == "" {
+		return "file"
+	}
+	return strings.Map(func(r rune) rune {
+		if r < 0x20 || strings.ContainsRune(`<>:"|?*`, r) {
+			return '_'
+		}
+		return r
+	}, name)
+}
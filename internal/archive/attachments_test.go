@@ -0,0 +1,182 @@
+package archive
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"purge/internal/discord"
+	"purge/internal/discord/discordtest"
+)
+
+func newDownloader(t *testing.T, maxSize int64) (*Downloader, string) {
+	t.Helper()
+	dir := t.TempDir()
+	d, err := NewDownloader(dir, maxSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { d.Close() })
+	return d, dir
+}
+
+func readManifest(t *testing.T, dir string) []ManifestEntry {
+	t.Helper()
+	f, err := os.Open(filepath.Join(dir, "manifest.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var entries []ManifestEntry
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		var e ManifestEntry
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			t.Fatal(err)
+		}
+		entries = append(entries, e)
+	}
+	return entries
+}
+
+func TestDownloaderManifest(t *testing.T) {
+	t.Parallel()
+	s := discordtest.NewServer("tok", discord.Profile{ID: "1"})
+	defer s.Close()
+	data := []byte("a picture")
+	a := s.AddAttachment("10", "cat.png", "image/png", data)
+
+	d, dir := newDownloader(t, 0)
+	if err := d.Write(context.Background(), discord.Message{ID: "100", ChannelID: "10", Attachments: []discord.Attachment{a}}); err != nil {
+		t.Fatal(err)
+	}
+
+	entries := readManifest(t, dir)
+	if len(entries) != 1 {
+		t.Fatalf("%d manifest entries, want 1", len(entries))
+	}
+	e := entries[0]
+	sum := sha256.Sum256(data)
+	if e.SHA256 != hex.EncodeToString(sum[:]) {
+		t.Errorf("sha256 = %s, want %x", e.SHA256, sum)
+	}
+	if e.MessageID != "100" || e.ChannelID != "10" || e.AttachmentID != a.ID || e.Size != int64(len(data)) || e.Skipped != "" {
+		t.Errorf("entry = %+v", e)
+	}
+	got, err := os.ReadFile(filepath.Join(dir, e.Path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Errorf("saved %q, want %q", got, data)
+	}
+}
+
+func TestDownloaderSizeCap(t *testing.T) {
+	t.Parallel()
+	s := discordtest.NewServer("tok", discord.Profile{ID: "1"})
+	defer s.Close()
+	big := s.AddAttachment("10", "big.bin", "", bytes.Repeat([]byte("x"), 100))
+	big.URL += "-gone" // Its size is known, so it mustn't be fetched at all.
+	// Discord doesn't always send a size, so the cap has to hold while downloading.
+	unsized := s.AddAttachment("10", "unsized.bin", "", bytes.Repeat([]byte("y"), 100))
+	unsized.Size = 0
+	small := s.AddAttachment("10", "small.bin", "", []byte("z"))
+
+	d, dir := newDownloader(t, 10)
+	m := discord.Message{ID: "100", ChannelID: "10", Attachments: []discord.Attachment{big, unsized, small}}
+	if err := d.Write(context.Background(), m); err != nil {
+		t.Fatal(err)
+	}
+
+	entries := readManifest(t, dir)
+	if len(entries) != 3 {
+		t.Fatalf("%d manifest entries, want 3", len(entries))
+	}
+	for _, e := range entries[:2] {
+		if e.Skipped == "" || e.Path != "" || e.SHA256 != "" {
+			t.Errorf("%s over the cap: %+v", e.Filename, e)
+		}
+	}
+	if e := entries[2]; e.Skipped != "" || e.Path == "" {
+		t.Errorf("small.bin under the cap: %+v", e)
+	}
+	files, _ := filepath.Glob(filepath.Join(dir, "10", "*"))
+	if len(files) != 1 {
+		t.Errorf("files saved: %v, want only small.bin", files)
+	}
+}
+
+func TestDownloaderFailure(t *testing.T) {
+	t.Parallel()
+	s := discordtest.NewServer("tok", discord.Profile{ID: "1"})
+	defer s.Close()
+	a := s.AddAttachment("10", "cat.png", "image/png", []byte("a picture"))
+	a.URL += "-gone"
+
+	d, dir := newDownloader(t, 0)
+	err := d.Write(context.Background(), discord.Message{ID: "100", ChannelID: "10", Attachments: []discord.Attachment{a}})
+	if err == nil {
+		t.Fatal("Write succeeded for an attachment that couldn't be downloaded")
+	}
+	if entries := readManifest(t, dir); len(entries) != 0 {
+		t.Errorf("manifest has %+v for a failed download", entries)
+	}
+	files, _ := filepath.Glob(filepath.Join(dir, "10", "*"))
+	if len(files) != 0 {
+		t.Errorf("files left behind: %v", files)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	a = s.AddAttachment("10", "dog.png", "image/png", []byte("another picture"))
+	if err := d.Write(ctx, discord.Message{ID: "101", ChannelID: "10", Attachments: []discord.Attachment{a}}); err == nil {
+		t.Error("Write succeeded with a cancelled context")
+	}
+}
+
+func TestSafeFilename(t *testing.T) {
+	tests := map[string]string{
+		"cat.png":              "cat.png",
+		"../../.bashrc":        ".bashrc",
+		"/etc/passwd":          "passwd",
+		`..\..\boot.ini`:       "boot.ini",
+		"..":                   "file",
+		"":                     "file",
+		"a<b>c:d\"e|f?g*h.txt": "a_b_c_d_e_f_g_h.txt",
+		"line\nbreak":          "line_break",
+	}
+	for name, want := range tests {
+		if got := safeFilename(name); got != want {
+			t.Errorf("safeFilename(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestDownloaderStaysInItsDirectory(t *testing.T) {
+	t.Parallel()
+	s := discordtest.NewServer("tok", discord.Profile{ID: "1"})
+	defer s.Close()
+	a := s.AddAttachment("10", "x", "", []byte("data"))
+	a.Filename = "../../../escaped"
+
+	d, dir := newDownloader(t, 0)
+	if err := d.Write(context.Background(), discord.Message{ID: "100", ChannelID: "10", Attachments: []discord.Attachment{a}}); err != nil {
+		t.Fatal(err)
+	}
+	e := readManifest(t, dir)[0]
+	if strings.Contains(e.Path, "..") || !strings.HasPrefix(e.Path, "10"+string(filepath.Separator)) {
+		t.Errorf("saved as %q, outside the channel directory", e.Path)
+	}
+	if _, err := os.Stat(filepath.Join(dir, e.Path)); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,108 @@
+package archive
+
+import (
+	"encoding/csv"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"purge/internal/discord"
+)
+
+const CSV Format = "csv"
+
+type Outcome string
+
+const (
+	OutcomeDeleted Outcome = "deleted"
+	OutcomeFailed  Outcome = "failed"
+	OutcomeSkipped Outcome = "skipped" // Matched but left alone, e.g. in a dry run.
+)
+
+// Recorder is told what happened to each message a purge matched.
+type Recorder interface {
+	Record(m discord.Message, outcome Outcome) error
+	io.Closer
+}
+
+var csvHeader = []string{"message_id", "channel_id", "timestamp", "author", "content", "attachments", "outcome"}
+
+// CSVWriter is a Recorder writing one spreadsheet row per message.
+type CSVWriter struct {
+	w   io.Writer
+	csv *csv.Writer
+}
+
+// NewCSVWriter writes to w, starting with a header row if header is true.
+// Close closes w if it is an io.Closer.
+func NewCSVWriter(w io.Writer, header bool) (*CSVWriter, error) {
+	cw := &CSVWriter{w: w, csv: csv.NewWriter(w)}
+	if header {
+		if err := cw.write(csvHeader); err != nil {
+			return nil, err
+		}
+	}
+	return cw, nil
+}
+
+func (c *CSVWriter) Record(m discord.Message, outcome Outcome) error {
+	return c.write([]string{
+		m.ID,
+		m.ChannelID,
+		m.Timestamp,
+		cell(m.Author.Username),
+		cell(m.Content),
+		strconv.Itoa(len(m.Attachments)),
+		string(outcome),
+	})
+}
+
+// cell keeps a spreadsheet from running text as a formula: anything starting with a
+// character that begins one gets a leading apostrophe, which spreadsheets hide.
+func cell(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
+
+// Rows are flushed straight away so the report is complete even if the purge is killed.
+func (c *CSVWriter) write(row []string) error {
+	if err := c.csv.Write(row); err != nil {
+		return err
+	}
+	c.csv.Flush()
+	return c.csv.Error()
+}
+
+func (c *CSVWriter) Close() error {
+	if cl, ok := c.w.(io.Closer); ok {
+		return cl.Close()
+	}
+	return nil
+}
+
+// OpenCSV opens path for appending, writing the header only if the file is new.
+func OpenCSV(path string) (*CSVWriter, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	cw, err := NewCSVWriter(f, info.Size() == 0)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return cw, nil
+}
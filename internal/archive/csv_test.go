@@ -0,0 +1,38 @@
+package archive
+
+import (
+	"bytes"
+	"encoding/csv"
+	"testing"
+
+	"purge/internal/discord"
+)
+
+func TestCSVEscapesFormulas(t *testing.T) {
+	tests := map[string]string{
+		"=HYPERLINK(\"x\")": "'=HYPERLINK(\"x\")",
+		"+1":                "'+1",
+		"-_-":               "'-_-",
+		"@everyone":         "'@everyone",
+		"\tcmd":             "'\tcmd",
+		"hello = world":     "hello = world",
+		"":                  "",
+	}
+	for content, want := range tests {
+		var b bytes.Buffer
+		w, err := NewCSVWriter(&b, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Record(discord.Message{ID: "1", Content: content}, OutcomeDeleted); err != nil {
+			t.Fatal(err)
+		}
+		row, err := csv.NewReader(&b).Read()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := row[4]; got != want {
+			t.Errorf("content %q written as %q, want %q", content, got, want)
+		}
+	}
+}
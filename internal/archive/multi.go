@@ -0,0 +1,32 @@
+package archive
+
+import (
+	"context"
+	"errors"
+
+	"purge/internal/discord"
+)
+
+type multiWriter []Writer
+
+// Multi writes each message to all writers in order, stopping at the first error.
+func Multi(writers ...Writer) Writer {
+	return multiWriter(writers)
+}
+
+func (mw multiWriter) Write(ctx context.Context, m discord.Message) error {
+	for _, w := range mw {
+		if err := w.Write(ctx, m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (mw multiWriter) Close() error {
+	var errs []error
+	for _, w := range mw {
+		errs = append(errs, w.Close())
+	}
+	return errors.Join(errs...)
+}
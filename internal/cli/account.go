@@ -0,0 +1,134 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"purge/internal/discord"
+)
+
+func runWhoami(args []string, stdout, stderr io.Writer) int {
+	fs, tokenFile := newFlagSet("whoami", stderr)
+	asJSON := fs.Bool("json", false, "print JSON")
+	if code := parseFlags(fs, args); code >= 0 {
+		return code
+	}
+
+	client, code := loginOrExit("whoami", *tokenFile, stderr)
+	if client == nil {
+		return code
+	}
+
+	if *asJSON {
+		return exitCode("whoami", writeJSON(stdout, client.UserInfo), stderr)
+	}
+	fmt.Fprintf(stdout, "%s (%s)\n", client.UserInfo.Username, client.UserInfo.ID)
+	return ExitOK
+}
+
+func runListDMs(args []string, stdout, stderr io.Writer) int {
+	fs, tokenFile := newFlagSet("list-dms", stderr)
+	asJSON := fs.Bool("json", false, "print the channels as JSON")
+	if code := parseFlags(fs, args); code >= 0 {
+		return code
+	}
+
+	client, code := loginOrExit("list-dms", *tokenFile, stderr)
+	if client == nil {
+		return code
+	}
+	ctx, stop := signalContext()
+	defer stop()
+	if err := client.FetchDMS(ctx); err != nil {
+		return exitCode("list-dms", err, stderr)
+	}
+
+	if *asJSON {
+		return exitCode("list-dms", writeJSON(stdout, client.DMS), stderr)
+	}
+	tw := tabwriter.NewWriter(stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tNAME")
+	for _, ch := range client.DMS {
+		fmt.Fprintf(tw, "%s\t%s\n", ch.ID, ch.DisplayName())
+	}
+	return exitCode("list-dms", tw.Flush(), stderr)
+}
+
+func runListGuilds(args []string, stdout, stderr io.Writer) int {
+	fs, tokenFile := newFlagSet("list-guilds", stderr)
+	asJSON := fs.Bool("json", false, "print the servers as JSON")
+	if code := parseFlags(fs, args); code >= 0 {
+		return code
+	}
+
+	client, code := loginOrExit("list-guilds", *tokenFile, stderr)
+	if client == nil {
+		return code
+	}
+	ctx, stop := signalContext()
+	defer stop()
+	guilds, err := client.FetchGuilds(ctx)
+	if err != nil {
+		return exitCode("list-guilds", err, stderr)
+	}
+
+	if *asJSON {
+		return exitCode("list-guilds", writeJSON(stdout, guilds), stderr)
+	}
+	tw := tabwriter.NewWriter(stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tNAME")
+	for _, g := range guilds {
+		fmt.Fprintf(tw, "%s\t%s\n", g.ID, g.Name)
+	}
+	return exitCode("list-guilds", tw.Flush(), stderr)
+}
+
+func runListChannels(args []string, stdout, stderr io.Writer) int {
+	fs, tokenFile := newFlagSet("list-channels", stderr)
+	guildID := fs.String("guild", "", "server ID, see list-guilds (required)")
+	asJSON := fs.Bool("json", false, "print the channels as JSON")
+	if code := parseFlags(fs, args); code >= 0 {
+		return code
+	}
+	if *guildID == "" {
+		fmt.Fprintln(stderr, "list-channels: -guild is required")
+		fs.Usage()
+		return ExitUsage
+	}
+
+	client, code := loginOrExit("list-channels", *tokenFile, stderr)
+	if client == nil {
+		return code
+	}
+	ctx, stop := signalContext()
+	defer stop()
+	channels, err := client.FetchGuildChannels(ctx, *guildID)
+	if err != nil {
+		return exitCode("list-channels", err, stderr)
+	}
+
+	// Categories, forums and the like can't be purged directly, so they are left out.
+	var text []discord.Channel
+	for _, ch := range discord.SortChannels(channels) {
+		if ch.HasMessages() {
+			text = append(text, ch)
+		}
+	}
+
+	if *asJSON {
+		return exitCode("list-channels", writeJSON(stdout, text), stderr)
+	}
+	category := make(map[string]string)
+	for _, ch := range channels {
+		if ch.Type == discord.ChannelTypeGuildCategory {
+			category[ch.ID] = ch.Name
+		}
+	}
+	tw := tabwriter.NewWriter(stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tNAME\tCATEGORY")
+	for _, ch := range text {
+		fmt.Fprintf(tw, "%s\t#%s\t%s\n", ch.ID, ch.Name, category[ch.ParentID])
+	}
+	return exitCode("list-channels", tw.Flush(), stderr)
+}
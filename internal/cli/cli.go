@@ -0,0 +1,225 @@
+// Package cli implements wipecord's non-interactive commands, for scripts and cron jobs.
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"purge/internal/discord"
+)
+
+// Exit codes returned by Run.
+const (
+	ExitOK          = 0
+	ExitError       = 1 // Anything not covered below, e.g. a network error.
+	ExitUsage       = 2
+	ExitAuth        = 3 // The token is missing or invalid.
+	ExitPartial     = 4 // The purge finished but some messages could not be deleted.
+	ExitInterrupted = 130
+)
+
+const (
+	tokenEnv  = "WIPECORD_TOKEN"
+	apiURLEnv = "WIPECORD_API_URL" // Overrides discord.DefaultBaseURL, e.g. for a test server.
+)
+
+type command struct {
+	name    string
+	summary string
+	run     func(args []string, stdout, stderr io.Writer) int
+}
+
+var commands = []command{
+	{"whoami", "show the account the token belongs to", runWhoami},
+	{"list-dms", "list your DMs and group DMs", runListDMs},
+	{"list-guilds", "list the servers you are in", runListGuilds},
+	{"list-channels", "list the text channels of a server", runListChannels},
+	{"export", "save HTML and Markdown transcripts of channels", runExport},
+	{"stats", "count your messages in channels without deleting anything", runStats},
+	{"purge", "delete your messages from one or more channels", runPurge},
+}
+
+// Run runs the command named in args[0] and returns the exit code.
+func Run(args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		usage(stderr)
+		return ExitUsage
+	}
+
+	switch args[0] {
+	case "help", "-h", "-help", "--help":
+		usage(stdout)
+		return ExitOK
+	}
+
+	for _, c := range commands {
+		if c.name == args[0] {
+			return c.run(args[1:], stdout, stderr)
+		}
+	}
+
+	fmt.Fprintf(stderr, "unknown command %q\n\n", args[0])
+	usage(stderr)
+	return ExitUsage
+}
+
+func usage(w io.Writer) {
+	fmt.Fprint(w, "Usage: wipecord [command] [flags]\n\n")
+	fmt.Fprint(w, "Without a command, wipecord starts the interactive TUI.\n\nCommands:\n")
+	for _, c := range commands {
+		fmt.Fprintf(w, "  %-12s %s\n", c.name, c.summary)
+	}
+	fmt.Fprintf(w, "\nThe token is read from the %s environment variable or -token-file.\n", tokenEnv)
+	fmt.Fprint(w, "Run \"wipecord <command> -h\" for the flags of a command.\n")
+}
+
+// newFlagSet returns a flag set with the -token-file flag every command shares.
+func newFlagSet(name string, stderr io.Writer) (*flag.FlagSet, *string) {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	tokenFile := fs.String("token-file", "", "read the token from this file instead of $"+tokenEnv)
+	return fs, tokenFile
+}
+
+// parseFlags parses args, returning -1 to carry on or the exit code to return.
+func parseFlags(fs *flag.FlagSet, args []string) int {
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return ExitOK
+		}
+		return ExitUsage
+	}
+	if fs.NArg() > 0 {
+		fmt.Fprintf(fs.Output(), "%s: unexpected argument %q\n", fs.Name(), fs.Arg(0))
+		return ExitUsage
+	}
+	return -1
+}
+
+// flagSet reports whether the flag name was given on the command line.
+func flagSet(fs *flag.FlagSet, name string) bool {
+	set := false
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
+
+// loginOrExit logs in, printing the error and returning its exit code on failure.
+func loginOrExit(name, tokenFile string, stderr io.Writer) (*discord.Client, int) {
+	client, err := login(tokenFile)
+	if err != nil {
+		fmt.Fprintf(stderr, "%s: %v\n", name, err)
+		if errors.Is(err, errAuth) {
+			return nil, ExitAuth
+		}
+		return nil, ExitError
+	}
+	return client, -1
+}
+
+// worse returns whichever of two exit codes should be reported for a run of several
+// purges: an interruption over an error, and an error over a partial purge.
+func worse(a, b int) int {
+	rank := func(code int) int {
+		switch code {
+		case ExitOK:
+			return 0
+		case ExitPartial:
+			return 1
+		case ExitInterrupted:
+			return 3
+		}
+		return 2 // ExitError, and anything else that failed outright.
+	}
+	if rank(b) > rank(a) {
+		return b
+	}
+	return a
+}
+
+// exitCode maps the error of a finished command to an exit code.
+func exitCode(name string, err error, stderr io.Writer) int {
+	switch {
+	case err == nil:
+		return ExitOK
+	case errors.Is(err, context.Canceled):
+		return ExitInterrupted
+	}
+	fmt.Fprintf(stderr, "%s: %v\n", name, err)
+	return ExitError
+}
+
+// signalContext is cancelled on Ctrl+C or SIGTERM, so long commands can stop cleanly.
+func signalContext() (context.Context, context.CancelFunc) {
+	return signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+}
+
+func writeJSON(w io.Writer, v any) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// loadToken reads the token from tokenFile if given, otherwise from the environment.
+func loadToken(tokenFile string) (string, error) {
+	if tokenFile != "" {
+		b, err := os.ReadFile(tokenFile)
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimSpace(string(b)), nil
+	}
+	if t := strings.TrimSpace(os.Getenv(tokenEnv)); t != "" {
+		return t, nil
+	}
+	return "", fmt.Errorf("no token: set %s or pass -token-file", tokenEnv)
+}
+
+var errAuth = errors.New("authentication failed")
+
+// login returns a client with the current user fetched, or an error wrapping errAuth.
+func login(tokenFile string) (*discord.Client, error) {
+	token, err := loadToken(tokenFile)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errAuth, err)
+	}
+	var opts []discord.Option
+	if u := os.Getenv(apiURLEnv); u != "" {
+		opts = append(opts, discord.WithBaseURL(u))
+	}
+	c := discord.NewClient(token, opts...)
+	if err := c.TokenCheck(); err != nil {
+		if errors.Is(err, discord.ErrInvalidToken) {
+			return nil, fmt.Errorf("%w: %v", errAuth, err)
+		}
+		return nil, err
+	}
+	return c, nil
+}
+
+// listFlag is a flag that can be repeated or given a comma-separated list.
+type listFlag []string
+
+func (l *listFlag) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *listFlag) Set(v string) error {
+	for _, s := range strings.Split(v, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			*l = append(*l, s)
+		}
+	}
+	return nil
+}
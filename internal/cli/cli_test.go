@@ -0,0 +1,172 @@
+package cli
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"purge/internal/config"
+	"purge/internal/discord"
+	"purge/internal/discord/discordtest"
+)
+
+// setup points the commands at api with token, keeping config and checkpoints in a
+// temporary directory.
+func setup(t *testing.T, api, token string) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv(apiURLEnv, api)
+	t.Setenv(tokenEnv, token)
+}
+
+func newServer(t *testing.T) *discordtest.Server {
+	t.Helper()
+	s := discordtest.NewServer("tok", discord.Profile{ID: "1", Username: "me"})
+	t.Cleanup(s.Close)
+	return s
+}
+
+func run(args ...string) (int, string) {
+	var stdout, stderr bytes.Buffer
+	code := Run(args, &stdout, &stderr)
+	return code, stderr.String()
+}
+
+func TestLoginExitCodes(t *testing.T) {
+	s := newServer(t)
+
+	setup(t, s.URL+"/api", "tok")
+	if code, stderr := run("whoami"); code != ExitOK {
+		t.Errorf("whoami = %d, want %d: %s", code, ExitOK, stderr)
+	}
+	if n := s.Requests(discordtest.RouteMe); n != 1 {
+		t.Errorf("%d requests to /users/@me, want 1", n)
+	}
+
+	setup(t, s.URL+"/api", "wrong")
+	if code, stderr := run("whoami"); code != ExitAuth {
+		t.Errorf("whoami with a wrong token = %d, want %d: %s", code, ExitAuth, stderr)
+	}
+
+	setup(t, s.URL+"/api", "")
+	if code, stderr := run("whoami"); code != ExitAuth {
+		t.Errorf("whoami without a token = %d, want %d: %s", code, ExitAuth, stderr)
+	}
+
+	// An outage is not a bad token.
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "down", http.StatusServiceUnavailable)
+	}))
+	defer down.Close()
+	setup(t, down.URL+"/api", "tok")
+	if code, stderr := run("whoami"); code != ExitError {
+		t.Errorf("whoami during an outage = %d, want %d: %s", code, ExitError, stderr)
+	}
+
+	if code, _ := run("whoami", "-nope"); code != ExitUsage {
+		t.Errorf("whoami -nope = %d, want %d", code, ExitUsage)
+	}
+}
+
+func TestPurgeReportsTheWorstChannel(t *testing.T) {
+	s := newServer(t)
+	me := discord.Author{ID: "1", Username: "me"}
+	for _, id := range []string{"10", "11"} {
+		s.AddChannel(discord.Channel{ID: id, Type: discord.ChannelTypeDM})
+		s.GenerateMessages(id, me, 1, "mine")
+	}
+	s.Deny("10")
+	setup(t, s.URL+"/api", "tok")
+
+	code, stderr := run("purge", "-channel", "10,11", "-search-delay", "1ms", "-delete-delay", "1ms", "-q")
+	if code != ExitError {
+		t.Errorf("purge = %d, want %d: %s", code, ExitError, stderr)
+	}
+	if n := len(s.Messages("11")); n != 0 {
+		t.Errorf("%d messages left in the channel after the failing one", n)
+	}
+}
+
+func TestWorse(t *testing.T) {
+	tests := []struct{ a, b, want int }{
+		{ExitOK, ExitPartial, ExitPartial},
+		{ExitPartial, ExitError, ExitError},
+		{ExitError, ExitPartial, ExitError},
+		{ExitError, ExitInterrupted, ExitInterrupted},
+		{ExitInterrupted, ExitError, ExitInterrupted},
+		{ExitPartial, ExitOK, ExitPartial},
+	}
+	for _, tt := range tests {
+		if got := worse(tt.a, tt.b); got != tt.want {
+			t.Errorf("worse(%d, %d) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+// writeConfig points the commands at a config file with data.
+func writeConfig(t *testing.T, data string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(config.PathEnv, path)
+}
+
+func TestPurgeConfig(t *testing.T) {
+	s := newServer(t)
+	me := discord.Author{ID: "1", Username: "me"}
+	for _, id := range []string{"10", "11", "12"} {
+		s.AddChannel(discord.Channel{ID: id, Type: discord.ChannelTypeDM})
+	}
+	lol := s.GenerateMessages("10", me, 1, "lol")
+	hi := s.GenerateMessages("10", me, 1, "hi")
+	bye := s.GenerateMessages("11", me, 1, "bye")
+	other := s.GenerateMessages("12", me, 1, "lol")
+	setup(t, s.URL+"/api", "tok")
+	writeConfig(t, `{
+		"search_delay": "1ms",
+		"delete_delay": 1,
+		"filter": "bye",
+		"presets": {"friends": {"channels": ["10", "11"], "filter": "lol"}}
+	}`)
+
+	// The preset picks the channels and its filter replaces the file's.
+	if code, stderr := run("purge", "-preset", "friends", "-q"); code != ExitOK {
+		t.Fatalf("purge -preset = %d: %s", code, stderr)
+	}
+	if got := s.Deleted(); len(got) != 1 || got[0] != lol[0].ID {
+		t.Errorf("deleted %v, want only %s", got, lol[0].ID)
+	}
+
+	// A -filter flag wins over both.
+	if code, stderr := run("purge", "-preset", "friends", "-filter", "hi", "-q"); code != ExitOK {
+		t.Fatalf("purge -preset -filter = %d: %s", code, stderr)
+	}
+	if got := s.Deleted(); len(got) != 2 || got[1] != hi[0].ID {
+		t.Errorf("deleted %v, want %s next", got, hi[0].ID)
+	}
+
+	// Without a preset the file's filter applies.
+	if code, stderr := run("purge", "-channel", "11,12", "-q"); code != ExitOK {
+		t.Fatalf("purge = %d: %s", code, stderr)
+	}
+	if got := s.Deleted(); len(got) != 3 || got[2] != bye[0].ID {
+		t.Errorf("deleted %v, want %s next", got, bye[0].ID)
+	}
+	if n := len(s.Messages("12")); n != 1 {
+		t.Errorf("%s deleted outside the filter", other[0].ID)
+	}
+
+	if code, _ := run("purge", "-preset", "enemies"); code != ExitUsage {
+		t.Errorf("purge with an unknown preset = %d, want %d", code, ExitUsage)
+	}
+
+	writeConfig(t, `{"delete_delay": "soon"}`)
+	if code, _ := run("purge", "-channel", "10"); code != ExitUsage {
+		t.Errorf("purge with a malformed duration in the config = %d, want %d", code, ExitUsage)
+	}
+}
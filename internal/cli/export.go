@@ -0,0 +1,77 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"purge/internal/discord"
+	"purge/internal/export"
+)
+
+func runExport(args []string, stdout, stderr io.Writer) int {
+	var channels listFlag
+	fs, tokenFile := newFlagSet("export", stderr)
+	fs.Var(&channels, "channel", "channel or DM ID to export, repeatable or comma-separated (required)")
+	out := fs.String("out", "", "directory for the transcripts (default ~/.config/wipecord/exports)")
+	searchDelay := fs.Duration("search-delay", time.Second, "delay between message pages")
+	if code := parseFlags(fs, args); code >= 0 {
+		return code
+	}
+	if len(channels) == 0 {
+		fmt.Fprintln(stderr, "export: -channel is required")
+		fs.Usage()
+		return ExitUsage
+	}
+
+	dir := *out
+	if dir == "" {
+		var err error
+		if dir, err = export.DefaultDir(); err != nil {
+			return exitCode("export", err, stderr)
+		}
+	}
+
+	client, code := loginOrExit("export", *tokenFile, stderr)
+	if client == nil {
+		return code
+	}
+	ctx, stop := signalContext()
+	defer stop()
+
+	// Only used for nicer titles, a channel that isn't a DM is titled by its ID.
+	client.FetchDMS(ctx)
+
+	exporter := export.NewExporter(client)
+	exporter.SearchDelay = *searchDelay
+
+	for _, ch := range channels {
+		msgs, err := exporter.Fetch(ctx, ch, func(n int) {
+			fmt.Fprintf(stdout, "%s fetched %d messages\n", ch, n)
+		})
+		if err != nil {
+			return exitCode("export", fmt.Errorf("%s: %w", ch, err), stderr)
+		}
+
+		htmlPath, mdPath, err := export.WriteFiles(dir, export.Transcript{
+			Title:     channelTitle(client, ch),
+			ChannelID: ch,
+			Exported:  time.Now(),
+			Messages:  msgs,
+		})
+		if err != nil {
+			return exitCode("export", err, stderr)
+		}
+		fmt.Fprintf(stdout, "%s wrote %s\n%s wrote %s\n", ch, htmlPath, ch, mdPath)
+	}
+	return ExitOK
+}
+
+func channelTitle(client *discord.Client, channelID string) string {
+	for _, dm := range client.DMS {
+		if dm.ID == channelID {
+			return dm.DisplayName()
+		}
+	}
+	return "Channel " + channelID
+}
@@ -0,0 +1,389 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"purge/internal/archive"
+	"purge/internal/config"
+	"purge/internal/datapackage"
+	"purge/internal/discord"
+	"purge/internal/purge"
+)
+
+type purgeOptions struct {
+	cfg        *config.Config // File values, overridden by flags.
+	channels   listFlag
+	guilds     listFlag
+	dataDir    string
+	preset     string
+	dryRun     bool
+	resume     bool
+	quiet      bool
+	jsonOut    bool
+	eventsPath string
+
+	events *purge.EventEncoder // Opened from eventsPath.
+}
+
+func runPurge(args []string, stdout, stderr io.Writer) int {
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Fprintln(stderr, "purge: config:", err)
+		return ExitUsage
+	}
+
+	// Flags write straight into cfg, so the file provides the defaults and flags win.
+	o := purgeOptions{cfg: cfg}
+	fs, tokenFile := newFlagSet("purge", stderr)
+	fs.Var(&o.channels, "channel", "channel or DM ID to purge, repeatable or comma-separated")
+	fs.Var(&o.guilds, "guild", "server ID to purge in every channel and thread, repeatable or comma-separated")
+	fs.StringVar(&o.dataDir, "data-package", "", "delete the messages listed in an extracted Discord data package, limited to -channel if given")
+	fs.StringVar(&o.preset, "preset", "", "purge the channels of a preset from the config file")
+	fs.StringVar(&cfg.Filter, "filter", cfg.Filter, `comma-separated keywords, or a filter expression after "expr:", e.g. 'expr: has:attachment AND NOT "keep"'`)
+	fs.StringVar(&cfg.After, "after", cfg.After, "only messages sent on or after this date or age, e.g. 2024-01-01 or 90d")
+	fs.StringVar(&cfg.Before, "before", cfg.Before, "only messages sent on or before this date or age, e.g. 2024-06-30 or 30d")
+	fs.DurationVar((*time.Duration)(&cfg.SearchDelay), "search-delay", time.Duration(cfg.SearchDelay), "delay between message pages")
+	fs.DurationVar((*time.Duration)(&cfg.DeleteDelay), "delete-delay", time.Duration(cfg.DeleteDelay), "delay between deletes")
+	fs.BoolVar(&cfg.Search, "search", cfg.Search, "find your messages with Discord's search instead of reading every message")
+	fs.IntVar(&cfg.MaxAttempts, "max-attempts", cfg.MaxAttempts, "attempts per message before counting it as failed")
+	fs.BoolVar(&o.dryRun, "dry-run", false, "only list the messages that would be deleted")
+	fs.BoolVar(&o.resume, "resume", true, "continue from a saved checkpoint if there is one")
+	fs.BoolVar(&cfg.Archive.Messages, "archive", cfg.Archive.Messages, "archive messages to JSON Lines before deleting them")
+	fs.BoolVar(&cfg.Archive.Attachments, "attachments", cfg.Archive.Attachments, "download attachments before deleting them")
+	fs.Float64Var(&cfg.Archive.MaxAttachmentMB, "max-attachment-mb", cfg.Archive.MaxAttachmentMB, "skip downloading attachments larger than this, 0 for no limit")
+	fs.BoolVar(&cfg.Archive.Report, "report", cfg.Archive.Report, "write a CSV report of matched messages")
+	fs.BoolVar(&o.quiet, "q", false, "only print the summary of each channel")
+	fs.BoolVar(&o.jsonOut, "json", false, "print progress as JSON Lines events instead of text")
+	fs.StringVar(&o.eventsPath, "events", "", "also append JSON Lines events to this file")
+
+	if code := parseFlags(fs, args); code >= 0 {
+		return code
+	}
+
+	if o.preset != "" {
+		preset, err := cfg.Preset(o.preset)
+		if err != nil {
+			fmt.Fprintln(stderr, "purge:", err)
+			return ExitUsage
+		}
+		o.channels = append(o.channels, preset.Channels...)
+		if preset.Filter != "" && !flagSet(fs, "filter") {
+			cfg.Filter = preset.Filter
+		}
+	}
+	if len(o.channels) == 0 && len(o.guilds) == 0 && o.dataDir == "" {
+		fmt.Fprintln(stderr, "purge: -channel, -guild, -preset or -data-package is required")
+		fs.Usage()
+		return ExitUsage
+	}
+
+	now := time.Now()
+	if _, err := purge.ParseDateBound(cfg.After, now, false); err != nil {
+		fmt.Fprintln(stderr, "purge: -after:", err)
+		return ExitUsage
+	}
+	if _, err := purge.ParseDateBound(cfg.Before, now, true); err != nil {
+		fmt.Fprintln(stderr, "purge: -before:", err)
+		return ExitUsage
+	}
+	if _, err := purge.ParseFilter(cfg.Filter); err != nil {
+		fmt.Fprintln(stderr, "purge: -filter:", err)
+		return ExitUsage
+	}
+
+	client, code := loginOrExit("purge", *tokenFile, stderr)
+	if client == nil {
+		return code
+	}
+
+	purger, err := purge.NewPurger(client)
+	if err != nil {
+		fmt.Fprintln(stderr, "purge:", err)
+		return ExitError
+	}
+	if err := cfg.Apply(purger, now); err != nil {
+		fmt.Fprintln(stderr, "purge:", err)
+		return ExitUsage
+	}
+	purger.SetDryRun(o.dryRun)
+	if !o.dryRun {
+		if store, err := purge.DefaultCheckpointStore(); err == nil {
+			purger.SetCheckpointStore(store)
+		}
+	}
+
+	if o.eventsPath != "" {
+		f, err := os.OpenFile(o.eventsPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+		if err != nil {
+			fmt.Fprintln(stderr, "purge: -events:", err)
+			return ExitError
+		}
+		defer f.Close()
+		o.events = purge.NewEventEncoder(f)
+	}
+
+	ctx, stop := signalContext()
+	defer stop()
+
+	// With a data package, -channel only picks channels out of it.
+	var plans []datapackage.Plan
+	if o.dataDir != "" {
+		pkg, err := datapackage.Open(o.dataDir)
+		if err == nil {
+			plans, err = pkg.Plans(o.channels...)
+		}
+		if err != nil {
+			fmt.Fprintln(stderr, "purge: -data-package:", err)
+			return ExitUsage
+		}
+		total := 0
+		for _, plan := range plans {
+			total += len(plan.Messages)
+		}
+		fmt.Fprintf(stderr, "data package: %d messages in %d channels\n", total, len(plans))
+		o.channels = nil
+	}
+
+	code = ExitOK
+	for _, plan := range plans {
+		c := purgeChannel(ctx, purger, plan.Channel.ID, plan.Messages, o, stdout, stderr)
+		code = worse(code, c)
+		if code == ExitInterrupted {
+			return code
+		}
+	}
+	for _, ch := range o.channels {
+		c := purgeChannel(ctx, purger, ch, nil, o, stdout, stderr)
+		code = worse(code, c)
+		if code == ExitInterrupted {
+			return code
+		}
+	}
+	for _, g := range o.guilds {
+		c := purgeGuild(ctx, purger, g, o, stdout, stderr)
+		code = worse(code, c)
+		if code == ExitInterrupted {
+			return code
+		}
+	}
+	return code
+}
+
+// purgeChannel purges channelID, or only deletes msgs from it if they are given.
+func purgeChannel(ctx context.Context, purger *purge.Purger, channelID string, msgs []discord.Message, o purgeOptions, stdout, stderr io.Writer) int {
+	if o.resume {
+		cp, err := purger.LoadCheckpoint(channelID)
+		if err != nil {
+			fmt.Fprintf(stderr, "%s: ignoring checkpoint: %v\n", channelID, err)
+		}
+		purger.ResumeFrom(cp)
+	} else {
+		purger.ResumeFrom(nil)
+	}
+
+	closeWriters, err := openWriters(purger, channelID, o)
+	if err != nil {
+		fmt.Fprintf(stderr, "%s: %v\n", channelID, err)
+		return ExitError
+	}
+	defer closeWriters()
+
+	var done purge.UpdateDone
+	push := newPush(o, stdout, channelID, &done)
+	if msgs != nil {
+		err = purger.PurgeMessages(ctx, channelID, msgs, push)
+	} else {
+		err = purger.Purge(ctx, channelID, push)
+	}
+	return purgeExitCode(channelID, err, done, stderr)
+}
+
+// purgeGuild purges every channel and thread of guildID. The archive and report are
+// shared by all of them and named by archive.GuildName.
+func purgeGuild(ctx context.Context, purger *purge.Purger, guildID string, o purgeOptions, stdout, stderr io.Writer) int {
+	// PurgeGuild resumes every checkpoint it finds, so the only way to start over is without them.
+	if !o.resume {
+		purger.SetCheckpointStore(nil)
+	}
+
+	closeWriters, err := openWriters(purger, archive.GuildName(guildID), o)
+	if err != nil {
+		fmt.Fprintf(stderr, "%s: %v\n", guildID, err)
+		return ExitError
+	}
+	defer closeWriters()
+
+	var done purge.UpdateDone
+	err = purger.PurgeGuild(ctx, guildID, newPush(o, stdout, guildID, &done))
+	return purgeExitCode(guildID, err, done, stderr)
+}
+
+// newPush returns the push func printing or encoding updates as the options ask.
+// The final UpdateDone is stored in done. Updates are labelled with target until
+// an UpdateChannel names the channel they come from.
+func newPush(o purgeOptions, stdout io.Writer, target string, done *purge.UpdateDone) func(purge.Update) {
+	var stdoutEvents *purge.EventEncoder
+	if o.jsonOut {
+		stdoutEvents = purge.NewEventEncoder(stdout)
+	}
+
+	label := target
+	push := func(u purge.Update) {
+		switch v := u.(type) {
+		case purge.UpdateChannel:
+			label = v.ChannelID
+		case purge.UpdateDone:
+			*done = v
+			label = target
+		}
+
+		if o.quiet {
+			switch u.(type) {
+			case purge.UpdateDone, purge.UpdateChannelDone:
+			default:
+				return
+			}
+		}
+		if stdoutEvents != nil {
+			stdoutEvents.Encode(u)
+			return
+		}
+		printUpdate(stdout, label, u)
+	}
+	if o.events != nil {
+		push = o.events.Tee(push)
+	}
+	return push
+}
+
+func purgeExitCode(target string, err error, done purge.UpdateDone, stderr io.Writer) int {
+	switch {
+	case purge.IsStopped(err):
+		return ExitInterrupted
+	case err != nil:
+		fmt.Fprintf(stderr, "%s: %v\n", target, err)
+		return ExitError
+	case done.Failed > 0:
+		return ExitPartial
+	}
+	return ExitOK
+}
+
+// openWriters sets the purger's archive and report, named as archive.Path does, returning
+// a func closing them.
+func openWriters(purger *purge.Purger, name string, o purgeOptions) (func(), error) {
+	var (
+		w archive.Writer
+		r *archive.CSVWriter
+	)
+	purger.SetArchive(nil)
+	purger.SetReport(nil)
+	closeAll := func() {
+		if w != nil {
+			w.Close()
+		}
+		if r != nil {
+			r.Close()
+		}
+	}
+
+	ac := o.cfg.Archive
+	dir, err := archive.DefaultDir()
+	if err != nil && (ac.Messages || ac.Attachments || ac.Report) {
+		return nil, err
+	}
+
+	if ac.Report {
+		if r, err = archive.OpenCSV(archive.Path(dir, name, archive.CSV)); err != nil {
+			return nil, err
+		}
+		purger.SetReport(r)
+	}
+
+	if o.dryRun {
+		return closeAll, nil
+	}
+
+	var writers []archive.Writer
+	if ac.Messages {
+		f, err := archive.OpenFile(archive.Path(dir, name, archive.JSONL), archive.JSONL)
+		if err != nil {
+			closeAll()
+			return nil, err
+		}
+		writers = append(writers, f)
+	}
+	if ac.Attachments {
+		d, err := archive.NewDownloader(filepath.Join(dir, "attachments"), int64(ac.MaxAttachmentMB*1024*1024))
+		if err != nil {
+			archive.Multi(writers...).Close()
+			closeAll()
+			return nil, err
+		}
+		writers = append(writers, d)
+	}
+	if len(writers) > 0 {
+		w = archive.Multi(writers...)
+		purger.SetArchive(w)
+	}
+	return closeAll, nil
+}
+
+func printUpdate(w io.Writer, channelID string, u purge.Update) {
+	switch u := u.(type) {
+	case purge.UpdateDeleted:
+		fmt.Fprintf(w, "%s deleted: %s\n", channelID, oneLine(u.Content, 80))
+	case purge.UpdateMatched:
+		fmt.Fprintf(w, "%s match %s %s: %s\n", channelID, u.ID, u.Timestamp.Format(time.RFC3339), oneLine(u.Content, 80))
+	case purge.UpdateFailed:
+		fmt.Fprintf(w, "%s failed: %s\n", channelID, u.Message)
+	case purge.UpdateRateLimited:
+		fmt.Fprintf(w, "%s rate limited, waiting %s\n", channelID, u.Timeout)
+	case purge.UpdateInfo:
+		fmt.Fprintf(w, "%s %s\n", channelID, u.Message)
+	case purge.UpdatePaused:
+		fmt.Fprintf(w, "%s paused\n", channelID)
+	case purge.UpdateResumed:
+		fmt.Fprintf(w, "%s resumed\n", channelID)
+	case purge.UpdateChannel:
+		fmt.Fprintf(w, "%s channel %d/%d: #%s\n", channelID, u.Index, u.Total, u.Name)
+	case purge.UpdateChannelDone:
+		printUpdate(w, channelID, purge.UpdateDone(u))
+	case purge.UpdateDone:
+		if u.GuildID != "" {
+			channelID = fmt.Sprintf("%s (%d channels)", u.GuildID, u.Channels)
+		}
+		state := "done"
+		if u.Stopped {
+			state = "stopped"
+		}
+		if u.DryRun {
+			fmt.Fprintf(w, "%s dry run %s: %d messages, %d attachments would be deleted", channelID, state, u.Matched, u.Attachments)
+			if u.Matched > 0 {
+				fmt.Fprintf(w, " (%s to %s)", u.Oldest.Format("2006-01-02"), u.Newest.Format("2006-01-02"))
+			}
+			fmt.Fprintf(w, ", throttled %d\n", u.Throttled)
+			return
+		}
+		fmt.Fprintf(w, "%s %s: deleted %d, failed %d, throttled %d\n", channelID, state, u.Deleted, u.Failed, u.Throttled)
+	}
+}
+
+// oneLine squashes newlines so each update stays on one line of output.
+func oneLine(s string, max int) string {
+	r := []rune(s)
+	for i, c := range r {
+		if c == '\n' || c == '\r' || c == '\t' {
+			r[i] = ' '
+		}
+	}
+	if len(r) > max {
+		return string(r[:max-3]) + "..."
+	}
+	return string(r)
+}
@@ -0,0 +1,98 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"purge/internal/export"
+	"purge/internal/purge"
+)
+
+type channelStats struct {
+	ChannelID   string    `json:"channel_id"`
+	Name        string    `json:"name"`
+	Total       int       `json:"total"`
+	Mine        int       `json:"mine"`
+	Matching    int       `json:"matching"`
+	Attachments int       `json:"attachments"`
+	Oldest      time.Time `json:"oldest,omitzero"`
+	Newest      time.Time `json:"newest,omitzero"`
+}
+
+func runStats(args []string, stdout, stderr io.Writer) int {
+	var channels listFlag
+	fs, tokenFile := newFlagSet("stats", stderr)
+	fs.Var(&channels, "channel", "channel or DM ID, repeatable or comma-separated (required)")
+	filter := fs.String("filter", "", "also count how many of your messages match this filter (keywords, or an expression after \"expr:\")")
+	searchDelay := fs.Duration("search-delay", time.Second, "delay between message pages")
+	asJSON := fs.Bool("json", false, "print JSON")
+	if code := parseFlags(fs, args); code >= 0 {
+		return code
+	}
+	if len(channels) == 0 {
+		fmt.Fprintln(stderr, "stats: -channel is required")
+		fs.Usage()
+		return ExitUsage
+	}
+	f, err := purge.ParseFilter(*filter)
+	if err != nil {
+		fmt.Fprintln(stderr, "stats: -filter:", err)
+		return ExitUsage
+	}
+
+	client, code := loginOrExit("stats", *tokenFile, stderr)
+	if client == nil {
+		return code
+	}
+	ctx, stop := signalContext()
+	defer stop()
+	client.FetchDMS(ctx)
+
+	exporter := export.NewExporter(client)
+	exporter.SearchDelay = *searchDelay
+
+	var all []channelStats
+	for _, ch := range channels {
+		msgs, err := exporter.Fetch(ctx, ch, nil)
+		if err != nil {
+			return exitCode("stats", fmt.Errorf("%s: %w", ch, err), stderr)
+		}
+
+		st := channelStats{ChannelID: ch, Name: channelTitle(client, ch), Total: len(msgs)}
+		for _, m := range msgs {
+			if m.Author.ID != client.UserInfo.ID {
+				continue
+			}
+			st.Mine++
+			st.Attachments += len(m.Attachments)
+			if f == nil || f.Match(m) {
+				st.Matching++
+			}
+			// msgs is oldest first.
+			if t := m.Time(); !t.IsZero() {
+				if st.Oldest.IsZero() {
+					st.Oldest = t
+				}
+				st.Newest = t
+			}
+		}
+		all = append(all, st)
+
+		if !*asJSON {
+			fmt.Fprintf(stdout, "%s (%s): %d messages, %d yours, %d attachments", st.Name, st.ChannelID, st.Total, st.Mine, st.Attachments)
+			if f != nil {
+				fmt.Fprintf(stdout, ", %d matching", st.Matching)
+			}
+			if st.Mine > 0 {
+				fmt.Fprintf(stdout, ", yours from %s to %s", st.Oldest.Format("2006-01-02"), st.Newest.Format("2006-01-02"))
+			}
+			fmt.Fprintln(stdout)
+		}
+	}
+
+	if *asJSON {
+		return exitCode("stats", writeJSON(stdout, all), stderr)
+	}
+	return ExitOK
+}
@@ -0,0 +1,153 @@
+// Package config loads the optional settings file with purge defaults and channel presets.
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"purge/internal/purge"
+)
+
+// PathEnv overrides the config file location.
+const PathEnv = "WIPECORD_CONFIG"
+
+type Config struct {
+	SearchDelay Duration `json:"search_delay,omitempty"`
+	DeleteDelay Duration `json:"delete_delay,omitempty"`
+	MaxAttempts int      `json:"max_attempts,omitempty"`
+	Search      bool     `json:"search,omitempty"` // Find messages with Discord's search, see Purger.SetSearch.
+
+	Filter string `json:"filter,omitempty"`
+	After  string `json:"after,omitempty"` // Same forms as the settings, e.g. "2024-01-01" or "90d".
+	Before string `json:"before,omitempty"`
+
+	Archive Archive `json:"archive"`
+
+	// Presets name groups of channels, e.g. "old-friends": {"channels": ["123", "456"]}.
+	Presets map[string]Preset `json:"presets,omitempty"`
+}
+
+type Archive struct {
+	Messages        bool    `json:"messages"`
+	Attachments     bool    `json:"attachments"`
+	MaxAttachmentMB float64 `json:"max_attachment_mb,omitempty"`
+	Report          bool    `json:"report"`
+}
+
+type Preset struct {
+	Channels []string `json:"channels"`
+	Filter   string   `json:"filter,omitempty"` // Overrides Config.Filter for this preset.
+}
+
+// Default matches the purger's built-in defaults.
+func Default() *Config {
+	return &Config{
+		SearchDelay: Duration(3000 * time.Millisecond),
+		DeleteDelay: Duration(2000 * time.Millisecond),
+		MaxAttempts: 3,
+	}
+}
+
+// Path returns the config file location, e.g. ~/.config/wipecord/config.json.
+func Path() (string, error) {
+	if p := os.Getenv(PathEnv); p != "" {
+		return p, nil
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "wipecord", "config.json"), nil
+}
+
+// Load reads the config file. A missing file is not an error and gives Default.
+func Load() (*Config, error) {
+	path, err := Path()
+	if err != nil {
+		return Default(), nil
+	}
+	return LoadFile(path)
+}
+
+// LoadFile reads path on top of Default, so settings left out keep their defaults.
+func LoadFile(path string) (*Config, error) {
+	cfg := Default()
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return cfg, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// Preset looks up a preset by name.
+func (c *Config) Preset(name string) (Preset, error) {
+	p, ok := c.Presets[name]
+	if !ok {
+		return Preset{}, fmt.Errorf("no preset named %q in the config file", name)
+	}
+	return p, nil
+}
+
+// Duration is a time.Duration written as "3s" or "1500ms" in the file.
+// Plain numbers are read as milliseconds, like the settings form.
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		var ms float64
+		if err := json.Unmarshal(data, &ms); err != nil {
+			return fmt.Errorf("invalid duration %s", data)
+		}
+		*d = Duration(ms * float64(time.Millisecond))
+		return nil
+	}
+
+	if ms, err := strconv.ParseFloat(s, 64); err == nil {
+		*d = Duration(ms * float64(time.Millisecond))
+		return nil
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// Apply sets p's delays, attempts, filter and date range from c.
+func (c *Config) Apply(p *purge.Purger, now time.Time) error {
+	p.SetSearchDelay(time.Duration(c.SearchDelay))
+	p.SetDeleteDelay(time.Duration(c.DeleteDelay))
+	p.SetMaxAttempts(c.MaxAttempts)
+	p.SetSearch(c.Search)
+
+	if err := p.SetFilterExpr(c.Filter); err != nil {
+		return fmt.Errorf("filter: %w", err)
+	}
+	after, err := purge.ParseDateBound(c.After, now, false)
+	if err != nil {
+		return fmt.Errorf("after: %w", err)
+	}
+	before, err := purge.ParseDateBound(c.Before, now, true)
+	if err != nil {
+		return fmt.Errorf("before: %w", err)
+	}
+	p.SetDateRange(after, before)
+	return nil
+}
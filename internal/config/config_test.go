@@ -0,0 +1,112 @@
+package config
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeFile(t *testing.T, data string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadFile(t *testing.T) {
+	cfg, err := LoadFile(filepath.Join(t.TempDir(), "missing.json"))
+	if err != nil {
+		t.Fatalf("missing file: %v", err)
+	}
+	if cfg.SearchDelay != Default().SearchDelay || cfg.MaxAttempts != Default().MaxAttempts {
+		t.Errorf("missing file gave %+v, want the defaults", cfg)
+	}
+
+	cfg, err = LoadFile(writeFile(t, `{
+		"delete_delay": "500ms",
+		"filter": "expr: has:link",
+		"after": "90d",
+		"archive": {"messages": true},
+		"presets": {"friends": {"channels": ["10", "11"], "filter": "lol"}}
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.DeleteDelay != Duration(500*time.Millisecond) {
+		t.Errorf("delete delay = %s, want 500ms", time.Duration(cfg.DeleteDelay))
+	}
+	// Settings left out keep their defaults.
+	if cfg.SearchDelay != Default().SearchDelay || cfg.MaxAttempts != 3 {
+		t.Errorf("search delay %s, max attempts %d, want the defaults", time.Duration(cfg.SearchDelay), cfg.MaxAttempts)
+	}
+	if cfg.Filter != "expr: has:link" || cfg.After != "90d" || !cfg.Archive.Messages || cfg.Archive.Attachments {
+		t.Errorf("loaded %+v", cfg)
+	}
+
+	for name, data := range map[string]string{
+		"malformed JSON":     `{"filter": }`,
+		"malformed duration": `{"search_delay": "soon"}`,
+		"wrong type":         `{"max_attempts": "three"}`,
+	} {
+		if _, err := LoadFile(writeFile(t, data)); err == nil {
+			t.Errorf("%s: LoadFile succeeded", name)
+		}
+	}
+}
+
+func TestDurationUnmarshal(t *testing.T) {
+	tests := map[string]time.Duration{
+		`"3s"`:     3 * time.Second,
+		`"1500ms"`: 1500 * time.Millisecond,
+		`"1m30s"`:  90 * time.Second,
+		`"250"`:    250 * time.Millisecond,
+		`2000`:     2 * time.Second,
+		`0.5`:      500 * time.Microsecond,
+	}
+	for in, want := range tests {
+		var d Duration
+		if err := json.Unmarshal([]byte(in), &d); err != nil {
+			t.Errorf("%s: %v", in, err)
+			continue
+		}
+		if time.Duration(d) != want {
+			t.Errorf("%s = %s, want %s", in, time.Duration(d), want)
+		}
+	}
+
+	for _, in := range []string{`"soon"`, `"3x"`, `true`, `[]`} {
+		var d Duration
+		if err := json.Unmarshal([]byte(in), &d); err == nil {
+			t.Errorf("%s: no error", in)
+		}
+	}
+
+	// Written the way it is read back.
+	data, err := json.Marshal(Duration(1500 * time.Millisecond))
+	if err != nil || string(data) != `"1.5s"` {
+		t.Errorf("Marshal = %s, %v", data, err)
+	}
+}
+
+func TestPreset(t *testing.T) {
+	cfg := Default()
+	cfg.Presets = map[string]Preset{"friends": {Channels: []string{"10", "11"}, Filter: "lol"}}
+
+	p, err := cfg.Preset("friends")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(p.Channels) != 2 || p.Channels[0] != "10" || p.Filter != "lol" {
+		t.Errorf("preset = %+v", p)
+	}
+	if _, err := cfg.Preset("enemies"); err == nil {
+		t.Error("no error for an unknown preset")
+	}
+	if _, err := Default().Preset("friends"); err == nil {
+		t.Error("no error without any presets")
+	}
+}
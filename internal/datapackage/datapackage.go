@@ -0,0 +1,273 @@
+// Package datapackage reads the messages out of Discord's data package ("Request your
+// data" in the privacy settings), so they can be deleted by ID without paging through
+// every channel, including channels that are no longer open.
+package datapackage
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"purge/internal/discord"
+)
+
+// Package is an extracted data package.
+type Package struct {
+	dir   string            // The messages folder.
+	index map[string]string // Channel ID to the name Discord gave it, e.g. "Direct Message with bob".
+}
+
+// Channel is one channel of the package.
+type Channel struct {
+	ID        string
+	Name      string // From the index, empty if Discord left it out.
+	GuildID   string
+	GuildName string
+}
+
+// Plan is what a purge of one channel from the package has to delete.
+type Plan struct {
+	Channel  Channel
+	Messages []discord.Message // Newest first, authored by the package owner.
+}
+
+// Open reads the package extracted to dir. dir can be the package root or its messages folder.
+func Open(dir string) (*Package, error) {
+	var candidates []string
+	for _, name := range []string{"messages", "Messages", "."} {
+		candidates = append(candidates, filepath.Join(dir, name))
+	}
+
+	for _, d := range candidates {
+		data, err := os.ReadFile(filepath.Join(d, "index.json"))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		// Names are null for channels Discord couldn't name, e.g. deleted ones.
+		var index map[string]*string
+		if err := json.Unmarshal(data, &index); err != nil {
+			return nil, fmt.Errorf("corrupt messages index in %s: %w", d, err)
+		}
+		pkg := &Package{dir: d, index: make(map[string]string, len(index))}
+		for id, name := range index {
+			if name != nil {
+				pkg.index[id] = *name
+			} else {
+				pkg.index[id] = ""
+			}
+		}
+		return pkg, nil
+	}
+	return nil, fmt.Errorf("no messages/index.json in %s, is it an extracted Discord data package?", dir)
+}
+
+// ChannelIDs lists the channels in the index, in ascending ID order, i.e. oldest channel first.
+func (pkg *Package) ChannelIDs() []string {
+	ids := make([]string, 0, len(pkg.index))
+	for id := range pkg.index {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return discord.SnowflakeLess(ids[i], ids[j]) })
+	return ids
+}
+
+// Plan reads the messages of channelID. A channel listed in the index without a folder has no messages.
+func (pkg *Package) Plan(channelID string) (Plan, error) {
+	name, ok := pkg.index[channelID]
+	if !ok {
+		return Plan{}, fmt.Errorf("channel %s is not in the data package", channelID)
+	}
+	plan := Plan{Channel: Channel{ID: channelID, Name: name}}
+
+	dir := pkg.channelDir(channelID)
+	if dir == "" {
+		return plan, nil
+	}
+
+	if data, err := os.ReadFile(filepath.Join(dir, "channel.json")); err == nil {
+		var info struct {
+			Name  string `json:"name"`
+			Guild *struct {
+				ID   string `json:"id"`
+				Name string `json:"name"`
+			} `json:"guild"`
+		}
+		if err := json.Unmarshal(data, &info); err != nil {
+			return plan, fmt.Errorf("corrupt %s: %w", filepath.Join(dir, "channel.json"), err)
+		}
+		if plan.Channel.Name == "" {
+			plan.Channel.Name = info.Name
+		}
+		if info.Guild != nil {
+			plan.Channel.GuildID, plan.Channel.GuildName = info.Guild.ID, info.Guild.Name
+		}
+	}
+
+	records, err := readMessages(dir)
+	if err != nil {
+		return plan, err
+	}
+	for _, r := range records {
+		plan.Messages = append(plan.Messages, r.message(channelID))
+	}
+	sort.Slice(plan.Messages, func(i, j int) bool { return discord.SnowflakeLess(plan.Messages[j].ID, plan.Messages[i].ID) })
+	return plan, nil
+}
+
+// Plans reads the given channels, or every channel in the package if none are given.
+// Channels without messages are left out.
+func (pkg *Package) Plans(channelIDs ...string) ([]Plan, error) {
+	if len(channelIDs) == 0 {
+		channelIDs = pkg.ChannelIDs()
+	}
+
+	var plans []Plan
+	for _, id := range channelIDs {
+		plan, err := pkg.Plan(id)
+		if err != nil {
+			return nil, err
+		}
+		if len(plan.Messages) > 0 {
+			plans = append(plans, plan)
+		}
+	}
+	return plans, nil
+}
+
+// Older packages name the folders after the bare channel ID, newer ones prefix a "c".
+func (pkg *Package) channelDir(channelID string) string {
+	for _, name := range []string{"c" + channelID, channelID} {
+		d := filepath.Join(pkg.dir, name)
+		if st, err := os.Stat(d); err == nil && st.IsDir() {
+			return d
+		}
+	}
+	return ""
+}
+
+// record is one message as the package stores it. Only the ID matters for deleting,
+// the rest is kept for filters and archives.
+type record struct {
+	ID          flexID `json:"ID"`
+	Contents    string `json:"Contents"`
+	Attachments string `json:"Attachments"` // Space separated URLs.
+}
+
+func (r record) message(channelID string) discord.Message {
+	m := discord.Message{
+		ID:        string(r.ID),
+		ChannelID: channelID,
+		Content:   r.Contents,
+	}
+	// The package's timestamps come in several formats, the snowflake is exact.
+	if t, err := discord.SnowflakeTime(m.ID); err == nil {
+		m.Timestamp = t.UTC().Format(time.RFC3339Nano)
+	}
+	for _, u := range strings.Fields(r.Attachments) {
+		m.Attachments = append(m.Attachments, discord.Attachment{URL: u, Filename: path.Base(u)})
+	}
+	return m
+}
+
+// readMessages reads messages.json from newer packages or messages.csv from older ones.
+func readMessages(dir string) ([]record, error) {
+	if data, err := os.ReadFile(filepath.Join(dir, "messages.json")); err == nil {
+		var records []record
+		if err := json.Unmarshal(data, &records); err != nil {
+			return nil, fmt.Errorf("corrupt %s: %w", filepath.Join(dir, "messages.json"), err)
+		}
+		return records, nil
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	f, err := os.Open(filepath.Join(dir, "messages.csv"))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return readCSV(f)
+}
+
+func readCSV(r io.Reader) ([]record, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+
+	header, err := cr.Read()
+	if err == io.EOF {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	// Some packages start the file with a byte order mark.
+	col := make(map[string]int)
+	for i, name := range header {
+		col[strings.TrimPrefix(name, "\ufeff")] = i
+	}
+	idCol, ok := col["ID"]
+	if !ok {
+		return nil, errors.New("messages.csv has no ID column")
+	}
+	field := func(row []string, name string) string {
+		if i, ok := col[name]; ok && i < len(row) {
+			return row[i]
+		}
+		return ""
+	}
+
+	var records []record
+	for {
+		row, err := cr.Read()
+		if err == io.EOF {
+			return records, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if idCol >= len(row) || row[idCol] == "" {
+			continue
+		}
+		records = append(records, record{
+			ID:          flexID(row[idCol]),
+			Contents:    field(row, "Contents"),
+			Attachments: field(row, "Attachments"),
+		})
+	}
+}
+
+// flexID is a snowflake written as a JSON number in newer packages and a string in older ones.
+type flexID string
+
+func (id *flexID) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*id = flexID(s)
+		return nil
+	}
+	var n json.Number
+	if err := json.Unmarshal(data, &n); err != nil {
+		return fmt.Errorf("invalid message ID %s", data)
+	}
+	if _, err := strconv.ParseUint(n.String(), 10, 64); err != nil {
+		return fmt.Errorf("invalid message ID %s", data)
+	}
+	*id = flexID(n.String())
+	return nil
+}
@@ -0,0 +1,114 @@
+package datapackage
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReadCSV(t *testing.T) {
+	in := "\ufeffID,Timestamp,Contents,Attachments\n" +
+		"900000000000000001,2020-01-01,hello,\n" +
+		",2020-01-02,no id,\n" +
+		"12,2016-01-01,\"with, comma\",https://cdn.example/a.png https://cdn.example/b.txt\n" +
+		"13\n"
+	records, err := readCSV(strings.NewReader(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []record{
+		{ID: "900000000000000001", Contents: "hello"},
+		{ID: "12", Contents: "with, comma", Attachments: "https://cdn.example/a.png https://cdn.example/b.txt"},
+		{ID: "13"},
+	}
+	if len(records) != len(want) {
+		t.Fatalf("records = %+v, want %+v", records, want)
+	}
+	for i := range want {
+		if records[i] != want[i] {
+			t.Errorf("record %d = %+v, want %+v", i, records[i], want[i])
+		}
+	}
+
+	if m := records[1].message("5"); len(m.Attachments) != 2 || m.Attachments[1].Filename != "b.txt" {
+		t.Errorf("attachments = %+v", m.Attachments)
+	}
+}
+
+func TestReadCSVWithoutRows(t *testing.T) {
+	if records, err := readCSV(strings.NewReader("")); err != nil || records != nil {
+		t.Errorf("empty file = %v, %v", records, err)
+	}
+	if _, err := readCSV(strings.NewReader("Timestamp,Contents\n2020-01-01,hi\n")); err == nil {
+		t.Error("no error for a file without an ID column")
+	}
+}
+
+func TestFlexID(t *testing.T) {
+	tests := []struct {
+		json string
+		want flexID
+		ok   bool
+	}{
+		{`"1234"`, "1234", true},
+		{`1234`, "1234", true},
+		// Larger than a float64 can hold exactly, it must not be rounded.
+		{`1180189826428170260`, "1180189826428170260", true},
+		{`-5`, "", false},
+		{`1.5`, "", false},
+		{`true`, "", false},
+	}
+	for _, tt := range tests {
+		var id flexID
+		err := json.Unmarshal([]byte(tt.json), &id)
+		if (err == nil) != tt.ok {
+			t.Errorf("%s: err = %v", tt.json, err)
+			continue
+		}
+		if id != tt.want {
+			t.Errorf("%s = %q, want %q", tt.json, id, tt.want)
+		}
+	}
+}
+
+func TestPlanIsNewestFirst(t *testing.T) {
+	dir := t.TempDir()
+	msgs := filepath.Join(dir, "messages")
+	write := func(name, data string) {
+		t.Helper()
+		path := filepath.Join(msgs, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("index.json", `{"10": "Direct Message with bob", "11": null}`)
+	write("c10/messages.json", `[{"ID": 99, "Contents": "old"}, {"ID": 1180189826428170260, "Contents": "new"}, {"ID": "100", "Contents": "mid"}]`)
+
+	pkg, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plan, err := pkg.Plan("10")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, m := range plan.Messages {
+		got = append(got, m.ID)
+	}
+	if want := []string{"1180189826428170260", "100", "99"}; strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("plan = %v, want %v", got, want)
+	}
+
+	if plan, err := pkg.Plan("11"); err != nil || len(plan.Messages) != 0 {
+		t.Errorf("channel without a folder = %+v, %v", plan, err)
+	}
+	if _, err := pkg.Plan("12"); err == nil {
+		t.Error("no error for a channel missing from the index")
+	}
+}
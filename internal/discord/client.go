@@ -1,61 +1,148 @@
 package discord
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 )
 
+const (
+	DefaultBaseURL    = "https://discord.com/api"
+	DefaultAPIVersion = 9
+)
+
 type Client struct {
-	Token    string
-	HTTP     *http.Client
-	UserInfo *Profile
-	DMS      []Channel
+	Token      string
+	HTTP       *http.Client
+	BaseURL    string
+	APIVersion int
+	UserAgent  string
+	UserInfo   *Profile
+	DMS        []Channel
+
+	limiter *rateLimiter // Nil sends every request right away.
+
+	// Set by WithTransport and WithTimeout, applied to HTTP once all options have run.
+	transport http.RoundTripper
+	timeout   time.Duration
 }
 
 func (e RateLimitError) Error() string {
 	return fmt.Sprintf("rate limited: retry after %s", e.RetryAfter)
 }
 
-func NewClient(token string) *Client {
-	return &Client{
-		Token: token,
-		HTTP:  &http.Client{},
+func (e *HTTPError) Error() string {
+	if e.Body != "" {
+		return fmt.Sprintf("%s: %s (%s)", e.Op, e.Text, e.Body)
 	}
+	return fmt.Sprintf("%s: status %s", e.Op, e.Text)
 }
 
-func (c *Client) Request(method, endpoint string, body io.Reader) (*http.Response, error) {
-	req, err := http.NewRequest(method, "https://discord.com/api/v9"+endpoint, body)
+// newHTTPError reads Discord's error message from resp, which must not be read yet.
+func newHTTPError(op string, resp *http.Response) *HTTPError {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	return &HTTPError{Op: op, Status: resp.StatusCode, Text: resp.Status, Body: strings.TrimSpace(string(body))}
+}
+
+// newRateLimitError reads retry_after from the body of a 429, which must not be read yet.
+func newRateLimitError(resp *http.Response) RateLimitError {
+	var data struct {
+		RetryAfter float64 `json:"retry_after"`
+	}
+	json.NewDecoder(resp.Body).Decode(&data)
+	return RateLimitError{RetryAfter: time.Duration(data.RetryAfter * float64(time.Second))}
+}
+
+// ErrInvalidToken is returned by TokenCheck when Discord doesn't accept the token.
+var ErrInvalidToken = errors.New("Invalid Token!")
+
+func NewClient(token string, opts ...Option) *Client {
+	c := &Client{
+		Token:      token,
+		HTTP:       &http.Client{},
+		BaseURL:    DefaultBaseURL,
+		APIVersion: DefaultAPIVersion,
+		limiter:    newRateLimiter(),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	// Applied last, so they also hold for an http.Client from WithHTTPClient, whatever
+	// order the options came in. The caller's client is copied rather than modified.
+	if c.transport != nil || c.timeout > 0 {
+		h := *c.HTTP
+		if c.transport != nil {
+			h.Transport = c.transport
+		}
+		if c.timeout > 0 {
+			h.Timeout = c.timeout
+		}
+		c.HTTP = &h
+	}
+	return c
+}
+
+// Endpoint returns the full URL for an API path, e.g. "/users/@me".
+func (c *Client) Endpoint(path string) string {
+	base := strings.TrimRight(c.BaseURL, "/")
+	if c.APIVersion > 0 {
+		base += fmt.Sprintf("/v%d", c.APIVersion)
+	}
+	return base + path
+}
+
+// Request sends a request to endpoint. It first waits until the rate limit bucket of
+// the endpoint has room, as far as earlier responses have told.
+func (c *Client) Request(ctx context.Context, method, endpoint string, body io.Reader) (*http.Response, error) {
+	if c.limiter != nil {
+		if err := c.limiter.wait(ctx, method, endpoint); err != nil {
+			return nil, err
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.Endpoint(endpoint), body)
 	if err != nil {
 		return nil, err
 	}
 	req.Header.Set("Authorization", c.Token)
 	req.Header.Set("Content-Type", "application/json")
-	return c.HTTP.Do(req)
+	if c.UserAgent != "" {
+		req.Header.Set("User-Agent", c.UserAgent)
+	}
+
+	resp, err := c.HTTP.Do(req)
+	if err == nil && c.limiter != nil {
+		c.limiter.update(method, endpoint, resp)
+	}
+	return resp, err
 }
 
+// TokenCheck fetches the current user, returning ErrInvalidToken if Discord rejects the
+// token. Any other failure, like a network error or an outage, is returned as it is.
 func (c *Client) TokenCheck() error {
-	resp, err := c.Request("GET", "/users/@me", nil)
-	if err != nil {
-		return err
-	}
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("Invalid Token!")
+	err := c.FetchCurrentUser()
+	var he *HTTPError
+	if errors.As(err, &he) && he.Status == http.StatusUnauthorized {
+		return ErrInvalidToken
 	}
-	return nil
+	return err
 }
 
-func (c *Client) FetchDMS() error {
-	resp, err := c.Request("GET", "/users/@me/channels", nil)
+func (c *Client) FetchDMS(ctx context.Context) error {
+	resp, err := c.Request(ctx, "GET", "/users/@me/channels", nil)
 	if err != nil {
 		return err
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("failed to get user info: status %s", resp.Status)
+		return newHTTPError("failed to get user info", resp)
 	}
 
 	var dm []Channel
@@ -69,14 +156,82 @@ func (c *Client) FetchDMS() error {
 	return nil
 }
 
+// guildPageSize is the most servers Discord returns per request.
+const guildPageSize = 200
+
+// FetchGuilds returns the servers the user is in, paging through them in ID order.
+func (c *Client) FetchGuilds(ctx context.Context) ([]Guild, error) {
+	var all []Guild
+	after := ""
+	for {
+		path := fmt.Sprintf("/users/@me/guilds?limit=%d", guildPageSize)
+		if after != "" {
+			path += "&after=" + after
+		}
+		guilds, err := c.fetchGuildPage(ctx, path)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, guilds...)
+		if len(guilds) < guildPageSize {
+			return all, nil
+		}
+		after = guilds[len(guilds)-1].ID
+	}
+}
+
+func (c *Client) fetchGuildPage(ctx context.Context, path string) ([]Guild, error) {
+	resp, err := c.Request(ctx, "GET", path, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, newHTTPError("failed to get guilds", resp)
+	}
+
+	var guilds []Guild
+	if err := json.NewDecoder(resp.Body).Decode(&guilds); err != nil {
+		return nil, err
+	}
+	return guilds, nil
+}
+
+// FetchGuildChannels returns the channels of guildID that the user can see, in no particular
+// order. A 429 is returned as a RateLimitError.
+func (c *Client) FetchGuildChannels(ctx context.Context, guildID string) ([]Channel, error) {
+	resp, err := c.Request(ctx, "GET", fmt.Sprintf("/guilds/%s/channels", guildID), nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return nil, newRateLimitError(resp)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, newHTTPError("failed to get channels", resp)
+	}
+
+	var channels []Channel
+	if err := json.NewDecoder(resp.Body).Decode(&channels); err != nil {
+		return nil, err
+	}
+	for i := range channels {
+		if channels[i].GuildID == "" {
+			channels[i].GuildID = guildID
+		}
+	}
+	return channels, nil
+}
+
 func (c *Client) FetchCurrentUser() error {
-	resp, err := c.Request("GET", "/users/@me", nil)
+	resp, err := c.Request(context.Background(), "GET", "/users/@me", nil)
 	if err != nil {
 		return err
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("failed to get user info: status %s", resp.Status)
+		return newHTTPError("failed to get user info", resp)
 	}
 
 	var user Profile
@@ -91,6 +246,14 @@ func (c *Client) FetchCurrentUser() error {
 func parseRateLimit(resp *http.Response) RateLimit {
 	rl := RateLimit{}
 
+	rl.Bucket = resp.Header.Get("X-RateLimit-Bucket")
+	rl.Global = resp.Header.Get("X-RateLimit-Global") == "true"
+	rl.Scope = resp.Header.Get("X-RateLimit-Scope")
+
+	if s := resp.Header.Get("X-RateLimit-Limit"); s != "" {
+		rl.Limit, _ = strconv.Atoi(s)
+	}
+
 	if s := resp.Header.Get("X-RateLimit-Remaining"); s != "" {
 		rl.Remaining, _ = strconv.Atoi(s)
 	}
@@ -105,7 +268,7 @@ func parseRateLimit(resp *http.Response) RateLimit {
 }
 
 /* Pagnation support if beforeID is set */
-func (c *Client) FetchMessages(channelID, beforeID string) ([]Message, RateLimit, error) {
+func (c *Client) FetchMessages(ctx context.Context, channelID, beforeID string) ([]Message, RateLimit, error) {
 
 	endpoint := fmt.Sprintf("/channels/%s/messages?limit=100", channelID)
 
@@ -113,7 +276,7 @@ func (c *Client) FetchMessages(channelID, beforeID string) ([]Message, RateLimit
 		endpoint += fmt.Sprintf("&before=%s", beforeID)
 	}
 
-	resp, err := c.Request("GET", endpoint, nil)
+	resp, err := c.Request(ctx, "GET", endpoint, nil)
 	if err != nil {
 		return nil, RateLimit{}, fmt.Errorf("failed to send request: %w", err)
 	}
@@ -136,8 +299,7 @@ func (c *Client) FetchMessages(channelID, beforeID string) ([]Message, RateLimit
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, rl, fmt.Errorf("failed to get messages: %s (%s)", resp.Status, body)
+		return nil, rl, newHTTPError("failed to get messages", resp)
 	}
 
 	var messages []Message
@@ -148,9 +310,9 @@ func (c *Client) FetchMessages(channelID, beforeID string) ([]Message, RateLimit
 	return messages, rl, nil
 }
 
-func (c *Client) DeleteMessage(channelID string, msg Message) (RateLimit, error) {
+func (c *Client) DeleteMessage(ctx context.Context, channelID string, msg Message) (RateLimit, error) {
 
-	resp, err := c.Request("DELETE", fmt.Sprintf("/channels/%s/messages/%s", channelID, msg.ID), nil)
+	resp, err := c.Request(ctx, "DELETE", fmt.Sprintf("/channels/%s/messages/%s", channelID, msg.ID), nil)
 
 	if err != nil {
 		return RateLimit{}, err
@@ -176,7 +338,5 @@ func (c *Client) DeleteMessage(channelID string, msg Message) (RateLimit, error)
 		return rl, nil
 	}
 
-	body, _ := io.ReadAll(resp.Body)
-	return rl, fmt.Errorf("delete failed: %s (%s)", resp.Status, body)
-
+	return rl, newHTTPError("delete failed", resp)
 }
@@ -0,0 +1,105 @@
+package discord_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"purge/internal/discord"
+	"purge/internal/discord/discordtest"
+)
+
+func TestFetchGuildsPages(t *testing.T) {
+	s := discordtest.NewServer("tok", discord.Profile{ID: "1", Username: "me"})
+	defer s.Close()
+	for i := range 450 {
+		s.AddGuild(discord.Guild{ID: strconv.Itoa(1000 + i), Name: "g" + strconv.Itoa(i)})
+	}
+
+	guilds, err := s.Client().FetchGuilds(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(guilds) != 450 {
+		t.Fatalf("%d guilds, want 450", len(guilds))
+	}
+	seen := make(map[string]bool)
+	for _, g := range guilds {
+		if seen[g.ID] {
+			t.Fatalf("guild %s twice", g.ID)
+		}
+		seen[g.ID] = true
+	}
+	if n := s.Requests(discordtest.RouteGuilds); n != 3 {
+		t.Errorf("%d guild requests, want 3", n)
+	}
+}
+
+func TestFetchMessagesHTTPError(t *testing.T) {
+	s := discordtest.NewServer("tok", discord.Profile{ID: "1", Username: "me"})
+	defer s.Close()
+	s.AddChannel(discord.Channel{ID: "10", Type: discord.ChannelTypeDM})
+	s.Deny("10")
+
+	_, _, err := s.Client().FetchMessages(context.Background(), "10", "")
+	var he *discord.HTTPError
+	if !errors.As(err, &he) || he.Status != http.StatusForbidden {
+		t.Fatalf("FetchMessages = %v, want an HTTPError with status 403", err)
+	}
+	_, _, err = s.Client().FetchMessages(context.Background(), "99", "")
+	if !errors.As(err, &he) || he.Status != http.StatusNotFound {
+		t.Errorf("FetchMessages of an unknown channel = %v, want status 404", err)
+	}
+}
+
+func TestClientWaitsForBucket(t *testing.T) {
+	s := discordtest.NewServer("tok", discord.Profile{ID: "1", Username: "me"})
+	defer s.Close()
+	s.AddChannel(discord.Channel{ID: "10", Type: discord.ChannelTypeDM})
+	s.LimitRoute(discordtest.RouteMessages, 2, 200*time.Millisecond)
+
+	c := s.Client()
+	for i := range 5 {
+		_, rl, err := c.FetchMessages(context.Background(), "10", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rl.Hit {
+			t.Fatalf("request %d ran into a 429", i+1)
+		}
+	}
+}
+
+func TestFetchArchivedThreadsRetriesPages(t *testing.T) {
+	s := discordtest.NewServer("tok", discord.Profile{ID: "1", Username: "me"})
+	defer s.Close()
+	s.AddGuild(discord.Guild{ID: "5", Name: "g"})
+	s.AddChannel(discord.Channel{ID: "20", GuildID: "5", Type: discord.ChannelTypeGuildText})
+	for i := range 150 {
+		s.AddChannel(discord.Channel{
+			ID:             strconv.Itoa(1000 + i),
+			GuildID:        "5",
+			ParentID:       "20",
+			Type:           discord.ChannelTypePublicThread,
+			ThreadMetadata: &discord.ThreadMetadata{Archived: true, ArchiveTimestamp: time.Unix(int64(i), 0).UTC().Format(time.RFC3339)},
+		})
+	}
+	// One request per window: every page after the first runs into a 429. Starting over
+	// from the first page would never get through.
+	s.LimitRoute(discordtest.RouteThreads, 1, 20*time.Millisecond)
+
+	threads, err := s.Client(discord.WithoutRateLimiter()).FetchArchivedThreads(context.Background(), "20")
+	if err != nil {
+		t.Fatal(err)
+	}
+	seen := make(map[string]bool)
+	for _, th := range threads {
+		seen[th.ID] = true
+	}
+	if len(threads) != 150 || len(seen) != 150 {
+		t.Errorf("%d threads, %d distinct, want 150", len(threads), len(seen))
+	}
+}
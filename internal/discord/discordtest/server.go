@@ -0,0 +1,699 @@
+// Package discordtest provides an in-process stand-in for the parts of the
+// Discord API that wipecord uses, so the client and purger can run offline.
+package discordtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"purge/internal/discord"
+)
+
+type Route string
+
+const (
+	RouteMe       Route = "me"
+	RouteDMs      Route = "dms"
+	RouteMessages Route = "messages"
+	RouteDelete   Route = "delete"
+	RouteGuilds   Route = "guilds"
+
+	RouteGuildChannels Route = "guild_channels"
+	RouteThreads       Route = "threads" // Active and archived thread lists.
+	RouteSearch        Route = "search"  // Channel and guild message search.
+	RouteChannel       Route = "channel"
+)
+
+// RateLimit is a scripted 429 response returned instead of the real one.
+type RateLimit struct {
+	RetryAfter time.Duration
+	Global     bool
+}
+
+// Epoch is the time of the first generated message. Each further generated ID is a minute later.
+var Epoch = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func snowflake(t time.Time) uint64 {
+	n, _ := strconv.ParseUint(discord.SnowflakeFromTime(t), 10, 64)
+	return n
+}
+
+type Server struct {
+	*httptest.Server
+
+	Token string
+	User  discord.Profile
+
+	mu       sync.Mutex
+	channels []discord.Channel
+	guilds   []discord.Guild
+	messages map[string][]discord.Message // newest first
+	scripted map[Route][]RateLimit
+	buckets  map[Route]*bucket // Routes with an enforced limit, see LimitRoute.
+	deleted  []string
+	denied   map[string]bool // Channels answering 403 Missing Access.
+	indexing int             // Searches left that answer 202 "index not ready".
+	requests map[Route]int
+	files    map[string][]byte
+	nextID   uint64
+}
+
+// NewServer starts a fake API that accepts token and reports user as the current user.
+// Call Close when done.
+func NewServer(token string, user discord.Profile) *Server {
+	s := &Server{
+		Token:    token,
+		User:     user,
+		messages: make(map[string][]discord.Message),
+		scripted: make(map[Route][]RateLimit),
+		buckets:  make(map[Route]*bucket),
+		requests: make(map[Route]int),
+		files:    make(map[string][]byte),
+		denied:   make(map[string]bool),
+		nextID:   snowflake(Epoch),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/users/@me", s.route(RouteMe, s.handleMe))
+	mux.HandleFunc("GET /api/users/@me/channels", s.route(RouteDMs, s.handleDMs))
+	mux.HandleFunc("GET /api/users/@me/guilds", s.route(RouteGuilds, s.handleGuilds))
+	mux.HandleFunc("GET /api/guilds/{guild}/channels", s.route(RouteGuildChannels, s.handleGuildChannels))
+	mux.HandleFunc("GET /api/guilds/{guild}/threads/active", s.route(RouteThreads, s.handleActiveThreads))
+	mux.HandleFunc("GET /api/channels/{channel}/threads/archived/public", s.route(RouteThreads, s.handleArchivedThreads(false)))
+	mux.HandleFunc("GET /api/channels/{channel}/users/@me/threads/archived/private", s.route(RouteThreads, s.handleArchivedThreads(true)))
+	mux.HandleFunc("GET /api/channels/{channel}/messages/search", s.route(RouteSearch, s.handleSearch))
+	mux.HandleFunc("GET /api/guilds/{guild}/messages/search", s.route(RouteSearch, s.handleSearch))
+	mux.HandleFunc("GET /api/channels/{channel}", s.route(RouteChannel, s.handleChannel))
+	mux.HandleFunc("GET /api/channels/{channel}/messages", s.route(RouteMessages, s.handleMessages))
+	mux.HandleFunc("DELETE /api/channels/{channel}/messages/{message}", s.route(RouteDelete, s.handleDelete))
+	// Stands in for cdn.discordapp.com, which doesn't check the token.
+	mux.HandleFunc("GET /attachments/{channel}/{attachment}/{filename}", s.handleAttachment)
+
+	s.Server = httptest.NewServer(stripVersion(mux))
+	return s
+}
+
+// stripVersion serves "/api/v10/..." like "/api/...", so clients work with any
+// WithAPIVersion, including none at all.
+func stripVersion(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if rest, ok := strings.CutPrefix(r.URL.Path, "/api/v"); ok {
+			version, path, _ := strings.Cut(rest, "/")
+			if _, err := strconv.Atoi(version); err == nil {
+				r2 := r.Clone(r.Context())
+				r2.URL.Path = "/api/" + path
+				r2.URL.RawPath = ""
+				r = r2
+			}
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// Client returns a discord.Client pointed at this server. opts may change the API version.
+func (s *Server) Client(opts ...discord.Option) *discord.Client {
+	opts = append([]discord.Option{discord.WithBaseURL(s.URL + "/api"), discord.WithAPIVersion(9)}, opts...)
+	return discord.NewClient(s.Token, opts...)
+}
+
+// AddChannel adds a DM, or a channel of ch.GuildID if that is set.
+func (s *Server) AddChannel(ch discord.Channel) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.channels = append(s.channels, ch)
+}
+
+func (s *Server) AddGuild(g discord.Guild) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.guilds = append(s.guilds, g)
+}
+
+// Deny makes channelID answer 403 Missing Access, like a channel the user can't read.
+func (s *Server) Deny(channelID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.denied[channelID] = true
+}
+
+// AddMessages stores msgs in channelID. Messages without an ID get the next free snowflake.
+func (s *Server) AddMessages(channelID string, msgs ...discord.Message) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, m := range msgs {
+		if m.ID == "" {
+			m.ID = s.newID()
+		}
+		m.ChannelID = channelID
+		s.messages[channelID] = append(s.messages[channelID], m)
+	}
+
+	list := s.messages[channelID]
+	sort.Slice(list, func(i, j int) bool { return discord.SnowflakeLess(list[j].ID, list[i].ID) })
+}
+
+// GenerateMessages adds n messages by author to channelID and returns them oldest first.
+// Their IDs and timestamps follow on from the previously generated message.
+func (s *Server) GenerateMessages(channelID string, author discord.Author, n int, content string) []discord.Message {
+	msgs := make([]discord.Message, n)
+	s.mu.Lock()
+	for i := range msgs {
+		id := s.newID()
+		t, _ := discord.SnowflakeTime(id)
+		msgs[i] = discord.Message{
+			ID:        id,
+			Author:    author,
+			Content:   content,
+			Timestamp: t.Format(time.RFC3339),
+		}
+	}
+	s.mu.Unlock()
+
+	s.AddMessages(channelID, msgs...)
+	return msgs
+}
+
+// AddAttachment hosts data on the server and returns an attachment pointing at it,
+// ready to put in a message.
+func (s *Server) AddAttachment(channelID, filename, contentType string, data []byte) discord.Attachment {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id := s.newID()
+	path := fmt.Sprintf("/attachments/%s/%s/%s", channelID, id, url.PathEscape(filename))
+	s.files[path] = data
+	return discord.Attachment{
+		ID:          id,
+		URL:         s.URL + path,
+		Filename:    filename,
+		Size:        int64(len(data)),
+		ContentType: contentType,
+	}
+}
+
+// newID must be called with s.mu held.
+func (s *Server) newID() string {
+	s.nextID += uint64(time.Minute/time.Millisecond) << 22
+	return strconv.FormatUint(s.nextID, 10)
+}
+
+// ScriptRateLimit makes the next n requests to route answer with a 429.
+func (s *Server) ScriptRateLimit(route Route, n int, rl RateLimit) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for range n {
+		s.scripted[route] = append(s.scripted[route], rl)
+	}
+}
+
+// bucket is an enforced rate limit: limit requests per window, counted from the first.
+type bucket struct {
+	limit  int
+	window time.Duration
+	used   int
+	reset  time.Time
+}
+
+// LimitRoute makes route answer with a 429 once it gets more than limit requests
+// within window, like a real Discord bucket. The bucket headers report it accurately.
+func (s *Server) LimitRoute(route Route, limit int, window time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.buckets[route] = &bucket{limit: limit, window: window}
+}
+
+// ScriptIndexing makes the next n searches answer 202 Accepted, like Discord does while
+// it is still indexing.
+func (s *Server) ScriptIndexing(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.indexing += n
+}
+
+// Messages returns what is left in channelID, newest first.
+func (s *Server) Messages(channelID string) []discord.Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]discord.Message(nil), s.messages[channelID]...)
+}
+
+// Deleted returns the IDs of deleted messages in the order they were deleted.
+func (s *Server) Deleted() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.deleted...)
+}
+
+// Requests returns how many requests route has received, including rate limited ones.
+func (s *Server) Requests(route Route) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests[route]
+}
+
+func (s *Server) route(route Route, h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		s.requests[route]++
+		var rl *RateLimit
+		if q := s.scripted[route]; len(q) > 0 {
+			rl = &q[0]
+			s.scripted[route] = q[1:]
+		}
+		limit, remaining, resetAfter := 5, 4, time.Second
+		if b := s.buckets[route]; b != nil && rl == nil {
+			now := time.Now()
+			if !now.Before(b.reset) {
+				b.used, b.reset = 0, now.Add(b.window)
+			}
+			limit, resetAfter = b.limit, b.reset.Sub(now)
+			if b.used >= b.limit {
+				rl = &RateLimit{RetryAfter: resetAfter}
+			} else {
+				b.used++
+				remaining = b.limit - b.used
+			}
+		}
+		s.mu.Unlock()
+
+		if r.Header.Get("Authorization") != s.Token {
+			writeJSON(w, http.StatusUnauthorized, map[string]any{"message": "401: Unauthorized", "code": 0})
+			return
+		}
+
+		if rl != nil {
+			writeRateLimited(w, route, limit, *rl)
+			return
+		}
+
+		setBucketHeaders(w, route, limit, remaining, resetAfter)
+		h(w, r)
+	}
+}
+
+func (s *Server) handleMe(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.User)
+}
+
+func (s *Server) handleDMs(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.guildChannels(""))
+}
+
+func (s *Server) handleGuildChannels(w http.ResponseWriter, r *http.Request) {
+	guildID := r.PathValue("guild")
+	if !s.hasGuild(guildID) {
+		writeJSON(w, http.StatusNotFound, map[string]any{"message": "Unknown Guild", "code": 10004})
+		return
+	}
+	channels := []discord.Channel{}
+	for _, ch := range s.guildChannels(guildID) {
+		if !ch.IsThread() {
+			channels = append(channels, ch)
+		}
+	}
+	writeJSON(w, http.StatusOK, channels)
+}
+
+func (s *Server) handleActiveThreads(w http.ResponseWriter, r *http.Request) {
+	guildID := r.PathValue("guild")
+	if !s.hasGuild(guildID) {
+		writeJSON(w, http.StatusNotFound, map[string]any{"message": "Unknown Guild", "code": 10004})
+		return
+	}
+	threads := []discord.Channel{}
+	for _, ch := range s.guildChannels(guildID) {
+		if ch.IsThread() && (ch.ThreadMetadata == nil || !ch.ThreadMetadata.Archived) {
+			threads = append(threads, ch)
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"threads": threads, "members": []any{}})
+}
+
+// handleArchivedThreads lists archived public threads, or private ones if private is set,
+// newest first. It pages like Discord, by archive time for public threads and by ID for private ones.
+func (s *Server) handleArchivedThreads(private bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		parentID := r.PathValue("channel")
+		if s.isDenied(parentID) {
+			writeJSON(w, http.StatusForbidden, map[string]any{"message": "Missing Access", "code": 50001})
+			return
+		}
+
+		limit := 50
+		if n, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && n > 0 {
+			limit = n
+		}
+		before := r.URL.Query().Get("before")
+
+		s.mu.Lock()
+		var threads []discord.Channel
+		for _, ch := range s.channels {
+			if ch.ParentID != parentID || ch.ThreadMetadata == nil || !ch.ThreadMetadata.Archived {
+				continue
+			}
+			if (ch.Type == discord.ChannelTypePrivateThread) != private {
+				continue
+			}
+			threads = append(threads, ch)
+		}
+		s.mu.Unlock()
+
+		key := func(ch discord.Channel) string {
+			if private {
+				return ch.ID
+			}
+			return ch.ThreadMetadata.ArchiveTimestamp
+		}
+		less := func(a, b string) bool {
+			if private {
+				return discord.SnowflakeLess(a, b)
+			}
+			return a < b
+		}
+		sort.Slice(threads, func(i, j int) bool { return less(key(threads[j]), key(threads[i])) })
+
+		page := []discord.Channel{}
+		hasMore := false
+		for _, ch := range threads {
+			if before != "" && !less(key(ch), before) {
+				continue
+			}
+			if len(page) == limit {
+				hasMore = true
+				break
+			}
+			page = append(page, ch)
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"threads": page, "members": []any{}, "has_more": hasMore})
+	}
+}
+
+// guildChannels returns the channels of guildID, or the DMs for "".
+func (s *Server) guildChannels(guildID string) []discord.Channel {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	channels := []discord.Channel{}
+	for _, ch := range s.channels {
+		if ch.GuildID == guildID {
+			channels = append(channels, ch)
+		}
+	}
+	return channels
+}
+
+// handleGuilds pages through the guilds in ID order like Discord: at most limit (200 by
+// default) after the ID in after.
+func (s *Server) handleGuilds(w http.ResponseWriter, r *http.Request) {
+	limit := 200
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 200 {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"message": "Invalid Form Body", "code": 50035})
+			return
+		}
+		limit = n
+	}
+	after := r.URL.Query().Get("after")
+
+	s.mu.Lock()
+	all := append([]discord.Guild{}, s.guilds...)
+	s.mu.Unlock()
+	sort.Slice(all, func(i, j int) bool { return discord.SnowflakeLess(all[i].ID, all[j].ID) })
+
+	page := []discord.Guild{}
+	for _, g := range all {
+		if after != "" && !discord.SnowflakeLess(after, g.ID) {
+			continue
+		}
+		page = append(page, g)
+		if len(page) == limit {
+			break
+		}
+	}
+	writeJSON(w, http.StatusOK, page)
+}
+
+func (s *Server) handleMessages(w http.ResponseWriter, r *http.Request) {
+	channelID := r.PathValue("channel")
+
+	limit := 50
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 100 {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"message": "Invalid Form Body", "code": 50035})
+			return
+		}
+		limit = n
+	}
+	before := r.URL.Query().Get("before")
+
+	s.mu.Lock()
+	all, ok := s.messages[channelID]
+	page := []discord.Message{}
+	for _, m := range all {
+		if before != "" && !discord.SnowflakeLess(m.ID, before) {
+			continue
+		}
+		page = append(page, m)
+		if len(page) == limit {
+			break
+		}
+	}
+	s.mu.Unlock()
+
+	if s.isDenied(channelID) {
+		writeJSON(w, http.StatusForbidden, map[string]any{"message": "Missing Access", "code": 50001})
+		return
+	}
+	if !ok && !s.hasChannel(channelID) {
+		writeJSON(w, http.StatusNotFound, map[string]any{"message": "Unknown Channel", "code": 10003})
+		return
+	}
+	writeJSON(w, http.StatusOK, page)
+}
+
+func (s *Server) handleChannel(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("channel")
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, ch := range s.channels {
+		if ch.ID == id {
+			writeJSON(w, http.StatusOK, ch)
+			return
+		}
+	}
+	writeJSON(w, http.StatusNotFound, map[string]any{"message": "Unknown Channel", "code": 10003})
+}
+
+// handleSearch supports the search parameters the client sends: author_id, channel_id,
+// content, has (link and file only), min_id, max_id and offset. Pages hold 25 messages, newest first.
+// Like Discord, a server channel can only be searched through its server.
+func (s *Server) handleSearch(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	guildID := r.PathValue("guild")
+	channelID := r.PathValue("channel")
+	if guildID != "" {
+		channelID = q.Get("channel_id")
+	} else if s.channelGuild(channelID) != "" {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"message": "Cannot execute action on this channel type", "code": 50024})
+		return
+	}
+	if channelID != "" && s.isDenied(channelID) {
+		writeJSON(w, http.StatusForbidden, map[string]any{"message": "Missing Access", "code": 50001})
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.indexing > 0 {
+		s.indexing--
+		writeJSON(w, http.StatusAccepted, map[string]any{
+			"message": "Index not yet available. Try again later", "code": 110000,
+			"documents_indexed": 0, "retry_after": 0.05,
+		})
+		return
+	}
+
+	inScope := func(id string) bool {
+		if channelID != "" {
+			return id == channelID
+		}
+		for _, ch := range s.channels {
+			if ch.ID == id {
+				return ch.GuildID == guildID
+			}
+		}
+		return false
+	}
+
+	var hits []discord.Message
+	for id, msgs := range s.messages {
+		if !inScope(id) {
+			continue
+		}
+		for _, m := range msgs {
+			if matchesSearch(m, q) {
+				hits = append(hits, m)
+			}
+		}
+	}
+	sort.Slice(hits, func(i, j int) bool { return discord.SnowflakeLess(hits[j].ID, hits[i].ID) })
+
+	offset, _ := strconv.Atoi(q.Get("offset"))
+	if offset > 9975 {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"message": "Invalid Form Body", "code": 50035})
+		return
+	}
+	groups := [][]discord.Message{}
+	for i := offset; i < len(hits) && i < offset+25; i++ {
+		groups = append(groups, []discord.Message{hits[i]})
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"total_results": len(hits), "messages": groups})
+}
+
+func matchesSearch(m discord.Message, q url.Values) bool {
+	if a := q.Get("author_id"); a != "" && m.Author.ID != a {
+		return false
+	}
+	if c := q.Get("content"); c != "" && !strings.Contains(strings.ToLower(m.Content), strings.ToLower(c)) {
+		return false
+	}
+	if id := q.Get("min_id"); id != "" && !discord.SnowflakeLess(id, m.ID) {
+		return false
+	}
+	if id := q.Get("max_id"); id != "" && !discord.SnowflakeLess(m.ID, id) {
+		return false
+	}
+	for _, h := range q["has"] {
+		switch h {
+		case "link":
+			if !strings.Contains(m.Content, "http://") && !strings.Contains(m.Content, "https://") {
+				return false
+			}
+		case "file":
+			if len(m.Attachments) == 0 {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func (s *Server) handleDelete(w http.ResponseWriter, r *http.Request) {
+	channelID, messageID := r.PathValue("channel"), r.PathValue("message")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list := s.messages[channelID]
+	for i, m := range list {
+		if m.ID != messageID {
+			continue
+		}
+		if m.Author.ID != s.User.ID {
+			writeJSON(w, http.StatusForbidden, map[string]any{"message": "Cannot delete a message authored by another user", "code": 50021})
+			return
+		}
+		s.messages[channelID] = append(list[:i:i], list[i+1:]...)
+		s.deleted = append(s.deleted, messageID)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	writeJSON(w, http.StatusNotFound, map[string]any{"message": "Unknown Message", "code": 10008})
+}
+
+func (s *Server) handleAttachment(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	data, ok := s.files[r.URL.EscapedPath()]
+	s.mu.Unlock()
+
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Write(data)
+}
+
+func (s *Server) isDenied(channelID string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.denied[channelID]
+}
+
+func (s *Server) hasGuild(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, g := range s.guilds {
+		if g.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// channelGuild returns the guild of channel id, "" for DMs and unknown channels.
+func (s *Server) channelGuild(id string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, ch := range s.channels {
+		if ch.ID == id {
+			return ch.GuildID
+		}
+	}
+	return ""
+}
+
+func (s *Server) hasChannel(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, ch := range s.channels {
+		if ch.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+func writeRateLimited(w http.ResponseWriter, route Route, limit int, rl RateLimit) {
+	secs := rl.RetryAfter.Seconds()
+	setBucketHeaders(w, route, limit, 0, rl.RetryAfter)
+	w.Header().Set("Retry-After", strconv.Itoa(int(secs+0.999)))
+	if rl.Global {
+		w.Header().Set("X-RateLimit-Global", "true")
+		w.Header().Set("X-RateLimit-Scope", "global")
+	} else {
+		w.Header().Set("X-RateLimit-Scope", "user")
+	}
+	writeJSON(w, http.StatusTooManyRequests, map[string]any{
+		"message":     "You are being rate limited.",
+		"retry_after": secs,
+		"global":      rl.Global,
+	})
+}
+
+func setBucketHeaders(w http.ResponseWriter, route Route, limit, remaining int, resetAfter time.Duration) {
+	h := w.Header()
+	h.Set("X-RateLimit-Bucket", fmt.Sprintf("fake-%s", route))
+	h.Set("X-RateLimit-Limit", strconv.Itoa(limit))
+	h.Set("X-RateLimit-Remaining", strconv.Itoa(remaining))
+	h.Set("X-RateLimit-Reset-After", strconv.FormatFloat(resetAfter.Seconds(), 'f', 3, 64))
+	h.Set("X-RateLimit-Reset", strconv.FormatFloat(float64(time.Now().Add(resetAfter).UnixMilli())/1000, 'f', 3, 64))
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,62 @@
+package discord
+
+import (
+	"net/http"
+	"time"
+)
+
+// Option configures a Client in NewClient.
+type Option func(*Client)
+
+// WithBaseURL points the client at a different API host, e.g. a local test server.
+// The API version is appended to it, so pass "http://localhost:8080/api", not ".../api/v9".
+func WithBaseURL(url string) Option {
+	return func(c *Client) {
+		c.BaseURL = url
+	}
+}
+
+// WithAPIVersion sets the version segment of the URL. 0 leaves it out entirely.
+func WithAPIVersion(v int) Option {
+	return func(c *Client) {
+		c.APIVersion = v
+	}
+}
+
+// WithTransport sets the RoundTripper of the client's http.Client.
+func WithTransport(rt http.RoundTripper) Option {
+	return func(c *Client) {
+		c.transport = rt
+	}
+}
+
+func WithUserAgent(ua string) Option {
+	return func(c *Client) {
+		c.UserAgent = ua
+	}
+}
+
+// WithTimeout limits how long each request may take, including reading the response.
+func WithTimeout(d time.Duration) Option {
+	return func(c *Client) {
+		c.timeout = d
+	}
+}
+
+// WithHTTPClient replaces the underlying http.Client. WithTransport and WithTimeout
+// still apply to it, whichever order the options are given in.
+func WithHTTPClient(h *http.Client) Option {
+	return func(c *Client) {
+		if h != nil {
+			c.HTTP = h
+		}
+	}
+}
+
+// WithoutRateLimiter sends every request right away instead of waiting for its rate limit
+// bucket to reset. 429s are still reported to the caller as before.
+func WithoutRateLimiter() Option {
+	return func(c *Client) {
+		c.limiter = nil
+	}
+}
@@ -0,0 +1,49 @@
+package discord
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+type stubTransport struct{}
+
+func (stubTransport) RoundTrip(*http.Request) (*http.Response, error) { return nil, nil }
+
+func TestOptionsApplyInAnyOrder(t *testing.T) {
+	orders := map[string][]Option{
+		"client first": {WithHTTPClient(&http.Client{}), WithTimeout(time.Second), WithTransport(stubTransport{})},
+		"client last":  {WithTimeout(time.Second), WithTransport(stubTransport{}), WithHTTPClient(&http.Client{})},
+	}
+	for name, opts := range orders {
+		c := NewClient("tok", opts...)
+		if c.HTTP.Timeout != time.Second {
+			t.Errorf("%s: timeout = %s, want 1s", name, c.HTTP.Timeout)
+		}
+		if _, ok := c.HTTP.Transport.(stubTransport); !ok {
+			t.Errorf("%s: transport = %T, want stubTransport", name, c.HTTP.Transport)
+		}
+	}
+}
+
+func TestWithHTTPClientIsNotModified(t *testing.T) {
+	h := &http.Client{}
+	c := NewClient("tok", WithHTTPClient(h), WithTimeout(time.Second))
+	if h.Timeout != 0 {
+		t.Errorf("caller's client got timeout %s", h.Timeout)
+	}
+	if c.HTTP == h {
+		t.Error("client shares the caller's http.Client after applying a timeout")
+	}
+}
+
+func TestEndpointVersion(t *testing.T) {
+	c := NewClient("tok", WithBaseURL("http://x/api/"), WithAPIVersion(10))
+	if got := c.Endpoint("/users/@me"); got != "http://x/api/v10/users/@me" {
+		t.Errorf("Endpoint = %q", got)
+	}
+	c = NewClient("tok", WithBaseURL("http://x/api"), WithAPIVersion(0))
+	if got := c.Endpoint("/users/@me"); got != "http://x/api/users/@me" {
+		t.Errorf("Endpoint without version = %q", got)
+	}
+}
@@ -0,0 +1,147 @@
+package discord
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// rateLimiter keeps track of Discord's rate limit buckets from the X-RateLimit headers,
+// so requests wait for a bucket to reset instead of running into a 429.
+//
+// Discord doesn't say up front which routes share a bucket. Each response names its
+// bucket, so a route is only limited once it has been requested at least once. Buckets
+// are per major parameter (the channel or guild ID), a bucket emptied in one channel
+// doesn't hold up another.
+type rateLimiter struct {
+	mu      sync.Mutex
+	routes  map[string]string  // Route, e.g. "DELETE /channels/{major}/messages/{id}", to bucket hash.
+	buckets map[string]*bucket // Bucket hash plus major parameter.
+	global  time.Time          // No requests at all before this, after a global 429.
+}
+
+type bucket struct {
+	limit     int
+	remaining int
+	reset     time.Time
+}
+
+func newRateLimiter() *rateLimiter {
+	return &rateLimiter{
+		routes:  make(map[string]string),
+		buckets: make(map[string]*bucket),
+	}
+}
+
+// wait blocks until a request to path may be sent without exceeding its bucket, then
+// counts it against the bucket.
+func (l *rateLimiter) wait(ctx context.Context, method, path string) error {
+	route, major := routeKey(method, path)
+	for {
+		d := l.reserve(route, major, time.Now())
+		if d <= 0 {
+			return nil
+		}
+		if err := Sleep(ctx, d); err != nil {
+			return err
+		}
+	}
+}
+
+// reserve takes one request from the bucket of route, or reports how long until it can.
+func (l *rateLimiter) reserve(route, major string, now time.Time) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if now.Before(l.global) {
+		return l.global.Sub(now)
+	}
+
+	hash, ok := l.routes[route]
+	if !ok {
+		return 0
+	}
+	b, ok := l.buckets[hash+":"+major]
+	if !ok {
+		return 0
+	}
+	if !now.Before(b.reset) {
+		b.remaining = b.limit
+	}
+	if b.remaining <= 0 {
+		return b.reset.Sub(now)
+	}
+	b.remaining--
+	return 0
+}
+
+// update records the rate limit headers of a response to a request to path.
+func (l *rateLimiter) update(method, path string, resp *http.Response) {
+	rl := parseRateLimit(resp)
+	now := time.Now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if resp.StatusCode == http.StatusTooManyRequests && (rl.Global || rl.Scope == "global") {
+		if reset := now.Add(retryAfterHeader(resp)); reset.After(l.global) {
+			l.global = reset
+		}
+		return
+	}
+
+	if rl.Bucket == "" {
+		return
+	}
+	route, major := routeKey(method, path)
+	l.routes[route] = rl.Bucket
+
+	key := rl.Bucket + ":" + major
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{}
+		l.buckets[key] = b
+	}
+	b.limit = rl.Limit
+	b.remaining = rl.Remaining
+	b.reset = now.Add(rl.ResetAfter)
+
+	// A 429 on a shared bucket may come with a longer Retry-After than the bucket's reset.
+	if resp.StatusCode == http.StatusTooManyRequests {
+		b.remaining = 0
+		if reset := now.Add(retryAfterHeader(resp)); reset.After(b.reset) {
+			b.reset = reset
+		}
+	}
+}
+
+// routeKey turns an API path into its route, with IDs replaced by placeholders, and the
+// major parameter the bucket is split by. The query string doesn't matter to Discord.
+func routeKey(method, path string) (route, major string) {
+	path, _, _ = strings.Cut(path, "?")
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	for i, part := range parts {
+		if _, err := strconv.ParseUint(part, 10, 64); err != nil {
+			continue
+		}
+		if i == 1 && (parts[0] == "channels" || parts[0] == "guilds" || parts[0] == "webhooks") {
+			major = part
+			parts[i] = "{major}"
+		} else {
+			parts[i] = "{id}"
+		}
+	}
+	return method + " /" + strings.Join(parts, "/"), major
+}
+
+// retryAfterHeader reads the Retry-After header, which is in whole seconds.
+func retryAfterHeader(resp *http.Response) time.Duration {
+	v, err := strconv.ParseFloat(resp.Header.Get("Retry-After"), 64)
+	if err != nil {
+		return 0
+	}
+	return time.Duration(v * float64(time.Second))
+}
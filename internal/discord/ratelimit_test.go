@@ -0,0 +1,96 @@
+package discord
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestRouteKey(t *testing.T) {
+	tests := []struct {
+		method, path string
+		route, major string
+	}{
+		{"GET", "/users/@me", "GET /users/@me", ""},
+		{"GET", "/channels/123/messages?limit=100&before=456", "GET /channels/{major}/messages", "123"},
+		{"DELETE", "/channels/123/messages/456", "DELETE /channels/{major}/messages/{id}", "123"},
+		{"GET", "/guilds/9/messages/search?author_id=1", "GET /guilds/{major}/messages/search", "9"},
+		{"GET", "/users/@me/guilds?limit=200&after=5", "GET /users/@me/guilds", ""},
+	}
+	for _, tt := range tests {
+		route, major := routeKey(tt.method, tt.path)
+		if route != tt.route || major != tt.major {
+			t.Errorf("routeKey(%s %s) = %q, %q, want %q, %q", tt.method, tt.path, route, major, tt.route, tt.major)
+		}
+	}
+}
+
+// response fakes a reply with bucket headers: remaining of limit left, resetting after reset.
+func response(status int, bucket string, limit, remaining int, reset time.Duration) *http.Response {
+	h := http.Header{}
+	h.Set("X-RateLimit-Bucket", bucket)
+	h.Set("X-RateLimit-Limit", strconv.Itoa(limit))
+	h.Set("X-RateLimit-Remaining", strconv.Itoa(remaining))
+	h.Set("X-RateLimit-Reset-After", strconv.FormatFloat(reset.Seconds(), 'f', 3, 64))
+	return &http.Response{StatusCode: status, Header: h}
+}
+
+func TestReserve(t *testing.T) {
+	l := newRateLimiter()
+	const path = "/channels/1/messages/2"
+	route, major := routeKey("DELETE", path)
+
+	if d := l.reserve(route, major, time.Now()); d != 0 {
+		t.Errorf("unknown route waits %s", d)
+	}
+
+	l.update("DELETE", path, response(http.StatusNoContent, "b", 2, 1, time.Second))
+	now := time.Now()
+	if d := l.reserve(route, major, now); d != 0 {
+		t.Errorf("last request of the bucket waits %s", d)
+	}
+	if d := l.reserve(route, major, now); d <= 0 || d > time.Second {
+		t.Errorf("empty bucket waits %s, want up to 1s", d)
+	}
+
+	// Buckets are per channel, and other routes sharing the hash share it too.
+	if d := l.reserve(route, "3", now); d != 0 {
+		t.Errorf("another channel waits %s", d)
+	}
+	other, _ := routeKey("DELETE", "/channels/1/messages/9")
+	if d := l.reserve(other, major, now); d <= 0 {
+		t.Error("another message in the same channel doesn't wait")
+	}
+
+	// Once reset, the bucket is full again.
+	if d := l.reserve(route, major, now.Add(2*time.Second)); d != 0 {
+		t.Errorf("reset bucket waits %s", d)
+	}
+}
+
+func TestReserveAfterGlobalLimit(t *testing.T) {
+	l := newRateLimiter()
+	resp := &http.Response{StatusCode: http.StatusTooManyRequests, Header: http.Header{}}
+	resp.Header.Set("X-RateLimit-Global", "true")
+	resp.Header.Set("Retry-After", "2")
+	l.update("GET", "/users/@me", resp)
+
+	route, major := routeKey("GET", "/channels/1/messages")
+	if d := l.reserve(route, major, time.Now()); d <= time.Second {
+		t.Errorf("request after a global 429 waits %s, want about 2s", d)
+	}
+}
+
+func TestBucket429WaitsForReset(t *testing.T) {
+	l := newRateLimiter()
+	const path = "/channels/1/messages"
+	resp := response(http.StatusTooManyRequests, "b", 5, 0, 300*time.Millisecond)
+	resp.Header.Set("Retry-After", "1")
+	l.update("GET", path, resp)
+
+	route, major := routeKey("GET", path)
+	if d := l.reserve(route, major, time.Now()); d <= 0 || d > time.Second {
+		t.Errorf("request after a 429 waits %s", d)
+	}
+}
@@ -0,0 +1,150 @@
+package discord
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// SearchPageSize is how many messages Discord returns per search page.
+const SearchPageSize = 25
+
+// SearchQuery selects messages with Discord's search, the way the search bar in the
+// client does. Results come newest first. Empty fields are left out.
+type SearchQuery struct {
+	// Scope. A guild channel has to be searched through its guild, so set both.
+	GuildID   string
+	ChannelID string
+
+	AuthorID string
+	Content  string
+	Has      []string // "link", "embed", "file", "image", "video", "sound", "sticker" or "poll".
+
+	// Only messages with IDs in (MinID, MaxID), see SnowflakeFromTime.
+	MinID string
+	MaxID string
+
+	Offset int // Results to skip. Discord refuses offsets past 9975.
+}
+
+type SearchResult struct {
+	TotalResults int
+	Messages     []Message
+}
+
+// IndexNotReadyError is returned when Discord answers a search with 202 Accepted, which
+// it does while it is still indexing the channel or guild. The search can be retried after RetryAfter.
+type IndexNotReadyError struct {
+	RetryAfter time.Duration
+}
+
+func (e IndexNotReadyError) Error() string {
+	return fmt.Sprintf("search index not ready, retry after %s", e.RetryAfter)
+}
+
+func (q SearchQuery) endpoint() string {
+	v := url.Values{}
+	path := fmt.Sprintf("/channels/%s/messages/search", q.ChannelID)
+	if q.GuildID != "" {
+		path = fmt.Sprintf("/guilds/%s/messages/search", q.GuildID)
+		if q.ChannelID != "" {
+			v.Set("channel_id", q.ChannelID)
+		}
+	}
+
+	if q.AuthorID != "" {
+		v.Set("author_id", q.AuthorID)
+	}
+	if q.Content != "" {
+		v.Set("content", q.Content)
+	}
+	for _, h := range q.Has {
+		v.Add("has", h)
+	}
+	if q.MinID != "" {
+		v.Set("min_id", q.MinID)
+	}
+	if q.MaxID != "" {
+		v.Set("max_id", q.MaxID)
+	}
+	if q.Offset > 0 {
+		v.Set("offset", strconv.Itoa(q.Offset))
+	}
+	if len(v) == 0 {
+		return path
+	}
+	return path + "?" + v.Encode()
+}
+
+// SearchMessages runs one page of q. A 429 is reported through RateLimit.Hit like FetchMessages,
+// an index that is still being built as IndexNotReadyError.
+func (c *Client) SearchMessages(ctx context.Context, q SearchQuery) (SearchResult, RateLimit, error) {
+	var result SearchResult
+
+	resp, err := c.Request(ctx, "GET", q.endpoint(), nil)
+	if err != nil {
+		return result, RateLimit{}, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	rl := parseRateLimit(resp)
+
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests:
+		var data struct {
+			RetryAfter float64 `json:"retry_after"`
+		}
+		json.NewDecoder(resp.Body).Decode(&data)
+
+		rl.Hit = true
+		rl.RetryAfter = time.Duration(data.RetryAfter * float64(time.Second))
+		return result, rl, nil
+
+	case http.StatusAccepted:
+		data := struct {
+			RetryAfter float64 `json:"retry_after"`
+		}{RetryAfter: 2}
+		json.NewDecoder(resp.Body).Decode(&data)
+		return result, rl, IndexNotReadyError{RetryAfter: time.Duration(data.RetryAfter * float64(time.Second))}
+
+	case http.StatusOK:
+	default:
+		return result, rl, newHTTPError("failed to search messages", resp)
+	}
+
+	// Each hit comes wrapped in a list, which used to hold the messages around it as well.
+	var data struct {
+		TotalResults int         `json:"total_results"`
+		Messages     [][]Message `json:"messages"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
+		return result, rl, err
+	}
+
+	result.TotalResults = data.TotalResults
+	for _, group := range data.Messages {
+		result.Messages = append(result.Messages, group...)
+	}
+	return result, rl, nil
+}
+
+// FetchChannel returns the channel with channelID, e.g. to find out which guild it is in.
+func (c *Client) FetchChannel(ctx context.Context, channelID string) (Channel, error) {
+	var ch Channel
+
+	resp, err := c.Request(ctx, "GET", fmt.Sprintf("/channels/%s", channelID), nil)
+	if err != nil {
+		return ch, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return ch, newHTTPError("failed to get channel", resp)
+	}
+
+	err = json.NewDecoder(resp.Body).Decode(&ch)
+	return ch, err
+}
@@ -0,0 +1,18 @@
+package discord
+
+import (
+	"context"
+	"time"
+)
+
+// Sleep waits for d, returning early with ctx.Err() if ctx is cancelled.
+func Sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
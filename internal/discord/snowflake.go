@@ -0,0 +1,37 @@
+package discord
+
+import (
+	"strconv"
+	"time"
+)
+
+// DiscordEpoch is the first millisecond of 2015, which snowflake timestamps count from.
+const DiscordEpoch = 1420070400000
+
+// SnowflakeTime returns the creation time encoded in a snowflake ID.
+func SnowflakeTime(id string) (time.Time, error) {
+	n, err := strconv.ParseUint(id, 10, 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.UnixMilli(int64(n>>22) + DiscordEpoch).UTC(), nil
+}
+
+// SnowflakeFromTime returns the lowest snowflake that could have been created at t,
+// for use as a before/after cursor. Times before the epoch give "0".
+func SnowflakeFromTime(t time.Time) string {
+	ms := t.UnixMilli() - DiscordEpoch
+	if ms < 0 {
+		return "0"
+	}
+	return strconv.FormatUint(uint64(ms)<<22, 10)
+}
+
+// SnowflakeLess reports whether snowflake a is older than b. IDs are compared as numbers;
+// they are decimal strings without leading zeros, so a shorter one is always smaller.
+func SnowflakeLess(a, b string) bool {
+	if len(a) != len(b) {
+		return len(a) < len(b)
+	}
+	return a < b
+}
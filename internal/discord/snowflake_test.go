@@ -0,0 +1,21 @@
+package discord
+
+import "testing"
+
+func TestSnowflakeLess(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"99", "100", true},
+		{"100", "99", false},
+		{"100", "101", true},
+		{"101", "101", false},
+		{"1180189826428170260", "1180189826428170261", true},
+	}
+	for _, tt := range tests {
+		if got := SnowflakeLess(tt.a, tt.b); got != tt.want {
+			t.Errorf("SnowflakeLess(%s, %s) = %t, want %t", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
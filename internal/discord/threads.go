@@ -0,0 +1,110 @@
+package discord
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+type threadList struct {
+	Threads []Channel `json:"threads"`
+	HasMore bool      `json:"has_more"`
+}
+
+// FetchActiveThreads returns the unarchived threads in guildID that the user can see.
+func (c *Client) FetchActiveThreads(ctx context.Context, guildID string) ([]Channel, error) {
+	list, err := c.fetchThreads(ctx, fmt.Sprintf("/guilds/%s/threads/active", guildID))
+	if err != nil {
+		return nil, err
+	}
+	return list.Threads, nil
+}
+
+// maxThreadPageRetries is how often a page of archived threads is retried after a 429
+// before FetchArchivedThreads gives up with the RateLimitError.
+const maxThreadPageRetries = 5
+
+// FetchArchivedThreads returns the archived public threads of channelID and the archived
+// private threads the user has joined. A page that runs into a rate limit is retried on
+// its own, so the pages before it aren't fetched again.
+func (c *Client) FetchArchivedThreads(ctx context.Context, channelID string) ([]Channel, error) {
+	var threads []Channel
+
+	// Public threads page by archive time, joined private ones by thread ID.
+	before := ""
+	for {
+		endpoint := fmt.Sprintf("/channels/%s/threads/archived/public?limit=100", channelID)
+		if before != "" {
+			endpoint += "&before=" + url.QueryEscape(before)
+		}
+		list, err := c.fetchThreadPage(ctx, endpoint)
+		if err != nil {
+			return nil, err
+		}
+		threads = append(threads, list.Threads...)
+		if !list.HasMore || len(list.Threads) == 0 {
+			break
+		}
+		last := list.Threads[len(list.Threads)-1]
+		if last.ThreadMetadata == nil || last.ThreadMetadata.ArchiveTimestamp == "" {
+			break
+		}
+		before = last.ThreadMetadata.ArchiveTimestamp
+	}
+
+	before = ""
+	for {
+		endpoint := fmt.Sprintf("/channels/%s/users/@me/threads/archived/private?limit=100", channelID)
+		if before != "" {
+			endpoint += "&before=" + before
+		}
+		list, err := c.fetchThreadPage(ctx, endpoint)
+		if err != nil {
+			return nil, err
+		}
+		threads = append(threads, list.Threads...)
+		if !list.HasMore || len(list.Threads) == 0 {
+			break
+		}
+		before = list.Threads[len(list.Threads)-1].ID
+	}
+
+	return threads, nil
+}
+
+// fetchThreadPage fetches one page of threads, waiting out up to maxThreadPageRetries 429s.
+func (c *Client) fetchThreadPage(ctx context.Context, endpoint string) (threadList, error) {
+	for retries := 0; ; retries++ {
+		list, err := c.fetchThreads(ctx, endpoint)
+		var rl RateLimitError
+		if !errors.As(err, &rl) || retries == maxThreadPageRetries {
+			return list, err
+		}
+		if err := Sleep(ctx, rl.RetryAfter); err != nil {
+			return list, err
+		}
+	}
+}
+
+func (c *Client) fetchThreads(ctx context.Context, endpoint string) (threadList, error) {
+	var list threadList
+
+	resp, err := c.Request(ctx, "GET", endpoint, nil)
+	if err != nil {
+		return list, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return list, newRateLimitError(resp)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return list, newHTTPError("failed to get threads", resp)
+	}
+
+	err = json.NewDecoder(resp.Body).Decode(&list)
+	return list, err
+}
@@ -1,6 +1,10 @@
 package discord
 
-import "time"
+import (
+	"sort"
+	"strings"
+	"time"
+)
 
 type UpdateType string
 
@@ -25,14 +29,27 @@ type Update struct {
 	Throttled int
 }
 
+// HTTPError is an API response with an unexpected status. Check Status with errors.As
+// rather than looking for the code in the error text, which can contain any ID.
+type HTTPError struct {
+	Op     string // What was attempted, e.g. "failed to get messages".
+	Status int
+	Text   string // The status line, e.g. "403 Forbidden".
+	Body   string // Discord's error message, if it sent one.
+}
+
 type RateLimitError struct {
 	RetryAfter time.Duration
 }
 
 type RateLimit struct {
 	RetryAfter time.Duration
+	Bucket     string // Hash of the bucket, shared by every route it covers.
+	Limit      int
 	Remaining  int
 	ResetAfter time.Duration
+	Global     bool   // The 429 was the global limit, not the route's.
+	Scope      string // "user", "global" or "shared" on a 429.
 	Hit        bool
 }
 
@@ -52,6 +69,121 @@ type Channel struct {
 	Icon           string `json:"icon,omitempty"`
 	OwnerID        string `json:"owner_id,omitempty"`
 	BlockedWarning bool   `json:"blocked_user_warning_dismissed,omitempty"`
+
+	// Only set on guild channels.
+	GuildID  string `json:"guild_id,omitempty"`
+	ParentID string `json:"parent_id,omitempty"` // Category, or the parent channel of a thread.
+	Position int    `json:"position,omitempty"`
+
+	ThreadMetadata *ThreadMetadata `json:"thread_metadata,omitempty"` // Only set on threads.
+}
+
+type ThreadMetadata struct {
+	Archived         bool   `json:"archived"`
+	ArchiveTimestamp string `json:"archive_timestamp,omitempty"`
+	Locked           bool   `json:"locked"`
+}
+
+// Channel types, see
+// https://discord.com/developers/docs/resources/channel#channel-object-channel-types
+const (
+	ChannelTypeGuildText          = 0
+	ChannelTypeDM                 = 1
+	ChannelTypeGuildVoice         = 2
+	ChannelTypeGroupDM            = 3
+	ChannelTypeGuildCategory      = 4
+	ChannelTypeGuildAnnouncement  = 5
+	ChannelTypeAnnouncementThread = 10
+	ChannelTypePublicThread       = 11
+	ChannelTypePrivateThread      = 12
+	ChannelTypeGuildStageVoice    = 13
+	ChannelTypeGuildDirectory     = 14
+	ChannelTypeGuildForum         = 15
+	ChannelTypeGuildMedia         = 16
+)
+
+// HasMessages reports whether the channel has its own message history. Categories,
+// forums and media channels only hold other channels or threads.
+func (ch Channel) HasMessages() bool {
+	switch ch.Type {
+	case ChannelTypeGuildCategory, ChannelTypeGuildDirectory, ChannelTypeGuildForum, ChannelTypeGuildMedia:
+		return false
+	}
+	return true
+}
+
+func (ch Channel) IsThread() bool {
+	switch ch.Type {
+	case ChannelTypeAnnouncementThread, ChannelTypePublicThread, ChannelTypePrivateThread:
+		return true
+	}
+	return false
+}
+
+// SortChannels returns guild channels in the order of the Discord sidebar: channels outside any
+// category first, then each category with its channels, all by position.
+func SortChannels(channels []Channel) []Channel {
+	category := make(map[string]Channel)
+	for _, ch := range channels {
+		if ch.Type == ChannelTypeGuildCategory {
+			category[ch.ID] = ch
+		}
+	}
+	// Each channel sorts under its category's position, uncategorised ones under -1.
+	group := func(ch Channel) int {
+		if ch.Type == ChannelTypeGuildCategory {
+			return ch.Position
+		}
+		if c, ok := category[ch.ParentID]; ok {
+			return c.Position
+		}
+		return -1
+	}
+
+	sorted := append([]Channel(nil), channels...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, b := sorted[i], sorted[j]
+		if ga, gb := group(a), group(b); ga != gb {
+			return ga < gb
+		}
+		if ca, cb := a.Type == ChannelTypeGuildCategory, b.Type == ChannelTypeGuildCategory; ca != cb {
+			return ca
+		}
+		return a.Position < b.Position
+	})
+	return sorted
+}
+
+// DisplayName names a DM the way the DM list shows it: the recipient, or a group's
+// name or members.
+func (ch Channel) DisplayName() string {
+	switch ch.Type {
+	case 1: // DM
+		if len(ch.Recipients) > 0 {
+			return ch.Recipients[0].Username
+		}
+		return "(unknown DM)"
+	case 3: // Group DM
+		if ch.Name != "" {
+			return ch.Name
+		}
+		var names []string
+		for _, r := range ch.Recipients {
+			names = append(names, r.Username)
+		}
+		return "(Group: " + strings.Join(names, ", ") + ")"
+	}
+	if ch.Name != "" {
+		return ch.Name
+	}
+	return "(unknown channel type)"
+}
+
+type Guild struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Icon  string `json:"icon,omitempty"`
+	Owner bool   `json:"owner"`
 }
 
 type User struct {
@@ -60,20 +192,74 @@ type User struct {
 }
 
 type Message struct {
-	ID          string       `json:"id"`
-	Content     string       `json:"content"`
-	Author      Author       `json:"author"`
-	Timestamp   string       `json:"timestamp"`
-	ChannelID   string       `json:"channel_id"`
-	Attachments []Attachment `json:"attachments"`
+	ID              string       `json:"id"`
+	Type            int          `json:"type"`
+	Content         string       `json:"content"`
+	Author          Author       `json:"author"`
+	Timestamp       string       `json:"timestamp"`
+	ChannelID       string       `json:"channel_id"`
+	Attachments     []Attachment `json:"attachments"`
+	Mentions        []User       `json:"mentions"`
+	MentionEveryone bool         `json:"mention_everyone"`
+
+	// Set on replies. ReferencedMessage is nil if the original was deleted.
+	MessageReference  *MessageReference `json:"message_reference,omitempty"`
+	ReferencedMessage *Message          `json:"referenced_message,omitempty"`
+}
+
+type MessageReference struct {
+	MessageID string `json:"message_id,omitempty"`
+	ChannelID string `json:"channel_id,omitempty"`
+	GuildID   string `json:"guild_id,omitempty"`
+}
+
+// Message types that are worth filtering on, see
+// https://discord.com/developers/docs/resources/message#message-object-message-types
+const (
+	MessageTypeDefault = 0
+	MessageTypeReply   = 19
+)
+
+// Deletable reports whether Discord lets the author delete the message. Some system
+// messages, like calls and group DM member changes, carry the user as author but can't be removed.
+func (m Message) Deletable() bool {
+	switch m.Type {
+	case 1, 2, 3, 4, 5, // Recipient add/remove, call, channel name/icon change
+		14, 15, 16, 17, // Guild discovery notices
+		21: // Thread starter message
+		return false
+	}
+	return true
+}
+
+// Time parses Timestamp, falling back to the time in the snowflake ID.
+// It returns the zero time if neither is usable.
+func (m Message) Time() time.Time {
+	if t, err := time.Parse(time.RFC3339Nano, m.Timestamp); err == nil {
+		return t
+	}
+	t, _ := SnowflakeTime(m.ID)
+	return t
 }
 
 type Author struct {
-	ID       string `json:"id"`
-	Username string `json:"username"`
+	ID         string `json:"id"`
+	Username   string `json:"username"`
+	GlobalName string `json:"global_name,omitempty"`
+}
+
+// DisplayName is the name shown in the Discord client.
+func (a Author) DisplayName() string {
+	if a.GlobalName != "" {
+		return a.GlobalName
+	}
+	return a.Username
 }
 
 type Attachment struct {
-	ID  string `json:"id"`
-	URL string `json:"url"`
+	ID          string `json:"id"`
+	URL         string `json:"url"`
+	Filename    string `json:"filename"`
+	Size        int64  `json:"size"`
+	ContentType string `json:"content_type,omitempty"`
 }
@@ -0,0 +1,121 @@
+// Package export renders readable transcripts of a whole conversation.
+package export
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"purge/internal/discord"
+)
+
+// Exporter reads every message in a channel, from all authors.
+type Exporter struct {
+	client *discord.Client
+
+	// SearchDelay is the pause between pages, like the purger's search delay.
+	SearchDelay time.Duration
+}
+
+func NewExporter(client *discord.Client) *Exporter {
+	return &Exporter{
+		client:      client,
+		SearchDelay: 1000 * time.Millisecond,
+	}
+}
+
+// Fetch pages through the whole channel and returns its messages oldest first.
+// progress, if not nil, is called with the running total after every page.
+func (e *Exporter) Fetch(ctx context.Context, channelID string, progress func(fetched int)) ([]discord.Message, error) {
+	const max429 = 10 // Like the purger, give up rather than keep hitting the limit.
+
+	var all []discord.Message
+	var before string
+	consec429 := 0
+
+	for {
+		msgs, rl, err := e.client.FetchMessages(ctx, channelID, before)
+
+		if rl.Hit {
+			consec429++
+			if consec429 >= max429 {
+				return nil, fmt.Errorf("too many consecutive 429s")
+			}
+			if err := discord.Sleep(ctx, rl.RetryAfter+250*time.Millisecond); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		consec429 = 0
+		if err != nil {
+			return nil, err
+		}
+		if len(msgs) == 0 {
+			break
+		}
+
+		all = append(all, msgs...)
+		before = msgs[len(msgs)-1].ID
+		if progress != nil {
+			progress(len(all))
+		}
+
+		if err := discord.Sleep(ctx, e.SearchDelay); err != nil {
+			return nil, err
+		}
+	}
+
+	// The API returns newest first, transcripts read top to bottom.
+	slices.Reverse(all)
+	return all, nil
+}
+
+// Transcript is a fetched conversation ready to render.
+type Transcript struct {
+	Title     string
+	ChannelID string
+	Exported  time.Time
+	Messages  []discord.Message // Oldest first.
+}
+
+// DefaultDir is where transcripts go unless told otherwise, e.g. ~/.config/wipecord/exports.
+func DefaultDir() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "wipecord", "exports"), nil
+}
+
+// WriteFiles renders t as <dir>/<channel ID>-<time>.html and .md and returns both paths.
+func WriteFiles(dir string, t Transcript) (htmlPath, mdPath string, err error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", "", err
+	}
+	base := filepath.Join(dir, fmt.Sprintf("%s-%s", t.ChannelID, t.Exported.Format("20060102-150405")))
+	htmlPath, mdPath = base+".html", base+".md"
+
+	if err := writeFile(htmlPath, t, WriteHTML); err != nil {
+		return "", "", err
+	}
+	if err := writeFile(mdPath, t, WriteMarkdown); err != nil {
+		return "", "", err
+	}
+	return htmlPath, mdPath, nil
+}
+
+func writeFile(path string, t Transcript, render func(w io.Writer, t Transcript) error) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	if err := render(f, t); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
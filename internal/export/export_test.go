@@ -0,0 +1,55 @@
+package export
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"purge/internal/discord"
+	"purge/internal/discord/discordtest"
+)
+
+func TestFetchGivesUpAfterConsecutiveRateLimits(t *testing.T) {
+	t.Parallel()
+	s := discordtest.NewServer("tok", discord.Profile{ID: "1", Username: "me"})
+	defer s.Close()
+	s.AddChannel(discord.Channel{ID: "10", Type: discord.ChannelTypeDM})
+	s.GenerateMessages("10", discord.Author{ID: "1", Username: "me"}, 1, "hi")
+	s.ScriptRateLimit(discordtest.RouteMessages, 100, discordtest.RateLimit{RetryAfter: time.Millisecond})
+
+	e := NewExporter(s.Client(discord.WithoutRateLimiter()))
+	e.SearchDelay = time.Millisecond
+	if _, err := e.Fetch(context.Background(), "10", nil); err == nil {
+		t.Fatal("Fetch succeeded through 100 consecutive 429s")
+	}
+	if n := s.Requests(discordtest.RouteMessages); n >= 100 {
+		t.Errorf("%d fetches, want Fetch to give up early", n)
+	}
+}
+
+func TestAttachmentLinkText(t *testing.T) {
+	tr := Transcript{
+		Title:     "DM",
+		ChannelID: "10",
+		Exported:  time.Now(),
+		Messages: []discord.Message{{
+			ID:          "1",
+			Timestamp:   time.Now().Format(time.RFC3339),
+			Attachments: []discord.Attachment{{ID: "5", URL: "https://cdn.example/5"}},
+		}},
+	}
+	for name, render := range map[string]func(*bytes.Buffer) error{
+		"html":     func(b *bytes.Buffer) error { return WriteHTML(b, tr) },
+		"markdown": func(b *bytes.Buffer) error { return WriteMarkdown(b, tr) },
+	} {
+		var b bytes.Buffer
+		if err := render(&b); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(b.String(), ">https://cdn.example/5</a>") && !strings.Contains(b.String(), "[https://cdn.example/5](") {
+			t.Errorf("%s: no link text for an attachment without a filename:\n%s", name, b.String())
+		}
+	}
+}
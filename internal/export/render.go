@@ -0,0 +1,122 @@
+package export
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"strings"
+	"time"
+
+	"purge/internal/discord"
+)
+
+const timeLayout = "2006-01-02 15:04"
+
+// replySnippet is the quoted text shown above a reply.
+func replySnippet(m discord.Message) string {
+	if m.MessageReference == nil {
+		return ""
+	}
+	ref := m.ReferencedMessage
+	if ref == nil {
+		return "Original message was deleted"
+	}
+	content := strings.Join(strings.Fields(ref.Content), " ")
+	if len([]rune(content)) > 80 {
+		content = string([]rune(content)[:77]) + "..."
+	}
+	if content == "" && len(ref.Attachments) > 0 {
+		content = "(attachment)"
+	}
+	return ref.Author.DisplayName() + ": " + content
+}
+
+func localTime(m discord.Message) string {
+	t := m.Time()
+	if t.IsZero() {
+		return m.Timestamp
+	}
+	return t.Local().Format(timeLayout)
+}
+
+var htmlTemplate = template.Must(template.New("transcript").Funcs(template.FuncMap{
+	"time":  localTime,
+	"reply": replySnippet,
+	"name":  attachmentName,
+	"date":  func(t time.Time) string { return t.Local().Format(timeLayout) },
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: sans-serif; background: #313338; color: #dbdee1; max-width: 60em; margin: 2em auto; padding: 0 1em; }
+header { border-bottom: 1px solid #4e5058; margin-bottom: 1em; }
+.msg { padding: .4em 0; }
+.author { font-weight: bold; color: #f2f3f5; }
+.time { color: #949ba4; font-size: .8em; margin-left: .5em; }
+.reply { color: #949ba4; font-size: .85em; border-left: 3px solid #4e5058; padding-left: .5em; margin-bottom: .2em; }
+.content { white-space: pre-wrap; word-wrap: break-word; }
+.attachments a { color: #00a8fc; display: block; }
+</style>
+</head>
+<body>
+<header>
+<h1>{{.Title}}</h1>
+<p>Channel {{.ChannelID}} &middot; {{len .Messages}} messages &middot; exported {{date .Exported}}</p>
+</header>
+{{range .Messages}}<div class="msg" id="m{{.ID}}">
+{{with reply .}}<div class="reply">&#8618; {{.}}</div>
+{{end}}<span class="author">{{.Author.DisplayName}}</span><span class="time">{{time .}}</span>
+{{if .Content}}<div class="content">{{.Content}}</div>
+{{end}}{{if .Attachments}}<div class="attachments">{{range .Attachments}}<a href="{{.URL}}">{{name .}}</a>{{end}}</div>
+{{end}}</div>
+{{end}}</body>
+</html>
+`))
+
+// WriteHTML renders t as a standalone HTML page.
+func WriteHTML(w io.Writer, t Transcript) error {
+	return htmlTemplate.Execute(w, t)
+}
+
+// WriteMarkdown renders t as Markdown. Message content is left as is, since Discord
+// messages are Markdown already.
+func WriteMarkdown(w io.Writer, t Transcript) error {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "# %s\n\n", t.Title)
+	fmt.Fprintf(&b, "Channel %s · %d messages · exported %s\n", t.ChannelID, len(t.Messages), t.Exported.Local().Format(timeLayout))
+
+	for _, m := range t.Messages {
+		b.WriteString("\n")
+		if r := replySnippet(m); r != "" {
+			fmt.Fprintf(&b, "> ↪ %s\n\n", r)
+		}
+		fmt.Fprintf(&b, "**%s** · %s\n", m.Author.DisplayName(), localTime(m))
+		if m.Content != "" {
+			fmt.Fprintf(&b, "\n%s\n", m.Content)
+		}
+		for _, a := range m.Attachments {
+			fmt.Fprintf(&b, "\n- [%s](%s)", attachmentName(a), a.URL)
+		}
+		if len(m.Attachments) > 0 {
+			b.WriteString("\n")
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// attachmentName is the link text for an attachment: its filename, or where it points
+// if it has none, so the link is never empty.
+func attachmentName(a discord.Attachment) string {
+	switch {
+	case a.Filename != "":
+		return a.Filename
+	case a.URL != "":
+		return a.URL
+	}
+	return a.ID
+}
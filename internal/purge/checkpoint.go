@@ -0,0 +1,156 @@
+package purge
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"purge/internal/discord"
+)
+
+// Checkpoint is the saved progress of a purge in one channel, so an interrupted purge
+// can carry on from the same cursor instead of rescanning from the newest message.
+type Checkpoint struct {
+	UserID     string    `json:"user_id"`
+	ChannelID  string    `json:"channel_id"`
+	Settings   string    `json:"settings"` // See Purger.settingsKey. Only a purge with the same key resumes it.
+	Before     string    `json:"before"`
+	Deleted    int       `json:"deleted"`
+	Failed     int       `json:"failed"`
+	Throttled  int       `json:"throttled"`
+	DeletedIDs IDSet     `json:"deleted_ids"`
+	FailedIDs  IDSet     `json:"failed_ids"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+func newCheckpoint(userID, channelID, settings string) *Checkpoint {
+	return &Checkpoint{
+		UserID:     userID,
+		ChannelID:  channelID,
+		Settings:   settings,
+		DeletedIDs: IDSet{},
+		FailedIDs:  IDSet{},
+	}
+}
+
+// Seen reports whether id was already deleted or gave up on in an earlier run.
+func (cp *Checkpoint) Seen(id string) bool {
+	return cp.DeletedIDs.Has(id) || cp.FailedIDs.Has(id)
+}
+
+// forgetPassed drops the IDs at or above Before. Pages only go further down from there,
+// so those messages can't come up again, and the sets stay the size of one page.
+func (cp *Checkpoint) forgetPassed() {
+	for _, set := range []IDSet{cp.DeletedIDs, cp.FailedIDs} {
+		for id := range set {
+			if !discord.SnowflakeLess(id, cp.Before) {
+				delete(set, id)
+			}
+		}
+	}
+}
+
+// IDSet is a set of message IDs, stored as a sorted JSON array.
+type IDSet map[string]struct{}
+
+func (s IDSet) Has(id string) bool {
+	_, ok := s[id]
+	return ok
+}
+
+func (s IDSet) Add(id string) {
+	s[id] = struct{}{}
+}
+
+func (s IDSet) MarshalJSON() ([]byte, error) {
+	ids := make([]string, 0, len(s))
+	for id := range s {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return json.Marshal(ids)
+}
+
+func (s *IDSet) UnmarshalJSON(data []byte) error {
+	var ids []string
+	if err := json.Unmarshal(data, &ids); err != nil {
+		return err
+	}
+	*s = make(IDSet, len(ids))
+	for _, id := range ids {
+		(*s).Add(id)
+	}
+	return nil
+}
+
+// CheckpointStore keeps one checkpoint file per account and channel in a directory.
+type CheckpointStore struct {
+	dir string
+}
+
+func NewCheckpointStore(dir string) *CheckpointStore {
+	return &CheckpointStore{dir: dir}
+}
+
+// DefaultCheckpointStore stores checkpoints under the user config dir, e.g. ~/.config/wipecord/checkpoints.
+func DefaultCheckpointStore() (*CheckpointStore, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return nil, err
+	}
+	return NewCheckpointStore(filepath.Join(dir, "wipecord", "checkpoints")), nil
+}
+
+// DM channel IDs are shared by both participants, so the file is keyed by user as well.
+func (s *CheckpointStore) path(userID, channelID string) string {
+	return filepath.Join(s.dir, fmt.Sprintf("%s-%s.json", userID, channelID))
+}
+
+// Load returns the checkpoint for channelID, or nil if there is none.
+func (s *CheckpointStore) Load(userID, channelID string) (*Checkpoint, error) {
+	data, err := os.ReadFile(s.path(userID, channelID))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	cp := newCheckpoint(userID, channelID, "")
+	if err := json.Unmarshal(data, cp); err != nil {
+		return nil, fmt.Errorf("corrupt checkpoint %s: %w", s.path(userID, channelID), err)
+	}
+	return cp, nil
+}
+
+// Save writes cp atomically, so a crash mid-write never leaves a truncated file behind.
+func (s *CheckpointStore) Save(cp *Checkpoint) error {
+	if err := os.MkdirAll(s.dir, 0o700); err != nil {
+		return err
+	}
+
+	cp.UpdatedAt = time.Now().UTC()
+	data, err := json.Marshal(cp)
+	if err != nil {
+		return err
+	}
+
+	path := s.path(cp.UserID, cp.ChannelID)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func (s *CheckpointStore) Remove(userID, channelID string) error {
+	err := os.Remove(s.path(userID, channelID))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
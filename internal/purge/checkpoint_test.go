@@ -0,0 +1,191 @@
+package purge
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"purge/internal/discord"
+)
+
+func TestCheckpointRoundTrip(t *testing.T) {
+	store := NewCheckpointStore(t.TempDir())
+
+	cp := newCheckpoint("1", "10", "key")
+	cp.Before = "500"
+	cp.Deleted, cp.Failed, cp.Throttled = 3, 1, 2
+	cp.DeletedIDs.Add("600")
+	cp.DeletedIDs.Add("700")
+	cp.FailedIDs.Add("650")
+	if err := store.Save(cp); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := store.Load("1", "10")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.UserID != "1" || got.ChannelID != "10" || got.Settings != "key" || got.Before != "500" ||
+		got.Deleted != 3 || got.Failed != 1 || got.Throttled != 2 {
+		t.Errorf("loaded %+v, want %+v", got, cp)
+	}
+	if !got.DeletedIDs.Has("600") || !got.DeletedIDs.Has("700") || !got.FailedIDs.Has("650") || len(got.DeletedIDs) != 2 {
+		t.Errorf("loaded IDs %v / %v", got.DeletedIDs, got.FailedIDs)
+	}
+	if got.UpdatedAt.IsZero() {
+		t.Error("UpdatedAt not set")
+	}
+
+	// Another account's checkpoint of the same DM is a different file.
+	if other, err := store.Load("2", "10"); err != nil || other != nil {
+		t.Errorf("Load of another user = %v, %v", other, err)
+	}
+
+	if err := store.Remove("1", "10"); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := store.Load("1", "10"); err != nil || got != nil {
+		t.Errorf("Load after Remove = %v, %v", got, err)
+	}
+	if err := store.Remove("1", "10"); err != nil {
+		t.Errorf("removing a missing checkpoint: %v", err)
+	}
+}
+
+func TestCheckpointForgetPassed(t *testing.T) {
+	cp := newCheckpoint("1", "10", "")
+	for _, id := range []string{"99", "100", "1000", "101"} {
+		cp.DeletedIDs.Add(id)
+	}
+	cp.FailedIDs.Add("150")
+	cp.FailedIDs.Add("50")
+	cp.Before = "100"
+	cp.forgetPassed()
+
+	if len(cp.DeletedIDs) != 1 || !cp.DeletedIDs.Has("99") {
+		t.Errorf("deleted IDs left: %v, want only 99", cp.DeletedIDs)
+	}
+	if len(cp.FailedIDs) != 1 || !cp.FailedIDs.Has("50") {
+		t.Errorf("failed IDs left: %v, want only 50", cp.FailedIDs)
+	}
+}
+
+func TestCheckpointWithOtherSettingsIsNotResumed(t *testing.T) {
+	t.Parallel()
+	s := newTestServer(t)
+	links := s.GenerateMessages("10", me, 2, "see https://example.com")
+	plain := s.GenerateMessages("10", me, 2, "plain")
+	p := newTestPurger(t, s)
+	p.SetCheckpointStore(NewCheckpointStore(t.TempDir()))
+	if err := p.SetFilterExpr("expr: has:link"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Stop the filtered purge after its first delete, leaving the cursor below the plain messages.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var r recorder
+	p.Purge(ctx, "10", func(u Update) {
+		r.push(u)
+		if _, ok := u.(UpdateDeleted); ok {
+			cancel()
+		}
+	})
+	cp, err := p.LoadCheckpoint("10")
+	if err != nil || cp == nil {
+		t.Fatalf("LoadCheckpoint = %v, %v", cp, err)
+	}
+	if !p.CanResume(cp) {
+		t.Error("CanResume = false with the same settings")
+	}
+
+	// Without the filter, the old cursor would skip the plain messages.
+	if err := p.SetFilterExpr(""); err != nil {
+		t.Fatal(err)
+	}
+	if p.CanResume(cp) {
+		t.Error("CanResume = true after changing the filter")
+	}
+	p.ResumeFrom(cp)
+	r = recorder{}
+	if err := p.Purge(context.Background(), "10", r.push); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := s.Deleted(), append(ids(links), ids(plain)...); !sameIDs(got, want) {
+		t.Errorf("deleted %v, want all of %v", got, want)
+	}
+	if d := r.done(t); d.Deleted != 3 {
+		t.Errorf("done = %+v, want the 3 messages left, counted from scratch", d)
+	}
+
+	// The date range and search setting count as well.
+	base := newTestPurger(t, s)
+	key := base.settingsKey(sourceChannel)
+	base.SetDateRange(time.Time{}, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	if base.settingsKey(sourceChannel) == key {
+		t.Error("settingsKey ignores the date range")
+	}
+	base.SetDateRange(time.Time{}, time.Time{})
+	base.SetSearch(true)
+	if base.settingsKey(sourceChannel) == key {
+		t.Error("settingsKey ignores search")
+	}
+	base.SetSearch(false)
+	if base.settingsKey(sourceList) == key {
+		t.Error("settingsKey ignores the source")
+	}
+	base.AddFilter("all", FilterFunc(func(discord.Message) bool { return true }))
+	if base.settingsKey(sourceChannel) == key {
+		t.Error("settingsKey ignores added filters")
+	}
+}
+
+func TestCheckpointWithOtherAddedFilterIsNotResumed(t *testing.T) {
+	t.Parallel()
+	s := newTestServer(t)
+	longer := func(n int) Filter {
+		return FilterFunc(func(m discord.Message) bool { return len(m.Content) > n })
+	}
+
+	a := newTestPurger(t, s)
+	a.AddFilter("longer=5", longer(5))
+	cp := newCheckpoint(me.ID, "10", a.settingsKey(sourceChannel))
+
+	same := newTestPurger(t, s)
+	same.AddFilter("longer=5", longer(5))
+	if !same.CanResume(cp) {
+		t.Error("CanResume = false with the same added filter")
+	}
+
+	// Same number of filters, but they select other messages.
+	other := newTestPurger(t, s)
+	other.AddFilter("longer=50", longer(50))
+	if other.CanResume(cp) {
+		t.Error("CanResume = true with an added filter under another key")
+	}
+}
+
+func TestAddFilterWithFilterExpr(t *testing.T) {
+	t.Parallel()
+	s := newTestServer(t)
+	short := s.GenerateMessages("10", me, 2, "see https://a.example")
+	long := s.GenerateMessages("10", me, 2, "see https://a.example for the long story")
+	s.GenerateMessages("10", me, 2, "no link here but long enough to pass")
+	p := newTestPurger(t, s)
+	if err := p.SetFilterExpr("expr: has:link"); err != nil {
+		t.Fatal(err)
+	}
+	p.AddFilter("longer=30", FilterFunc(func(m discord.Message) bool { return len(m.Content) > 30 }))
+
+	var r recorder
+	if err := p.Purge(context.Background(), "10", r.push); err != nil {
+		t.Fatal(err)
+	}
+	// Both have to match: the short links and the long messages without one stay.
+	if got := s.Deleted(); !sameIDs(got, ids(long)) {
+		t.Errorf("deleted %v, want %v", got, ids(long))
+	}
+	if n := len(s.Messages("10")); n != len(short)+2 {
+		t.Errorf("%d messages left, want %d", n, len(short)+2)
+	}
+}
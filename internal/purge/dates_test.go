@@ -0,0 +1,110 @@
+package purge
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"purge/internal/discord/discordtest"
+)
+
+func TestParseDateBound(t *testing.T) {
+	now := time.Date(2024, 3, 15, 12, 30, 0, 0, time.UTC)
+	tests := []struct {
+		in       string
+		endOfDay bool
+		want     time.Time
+	}{
+		{"", false, time.Time{}},
+		{"  ", true, time.Time{}},
+		{"2024-01-01", false, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"2024-01-01", true, time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)},
+		{"2024-02-29", true, time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)},
+		{"2024-01-01T15:04:05Z", false, time.Date(2024, 1, 1, 15, 4, 5, 0, time.UTC)},
+		{"2024-01-01T15:04:05Z", true, time.Date(2024, 1, 1, 15, 4, 5, 0, time.UTC)},
+		{"30d", false, now.AddDate(0, 0, -30)},
+		{"2w", false, now.AddDate(0, 0, -14)},
+		{"12h", false, now.Add(-12 * time.Hour)},
+		{"0d", false, now},
+	}
+	for _, tt := range tests {
+		got, err := ParseDateBound(tt.in, now, tt.endOfDay)
+		if err != nil {
+			t.Errorf("ParseDateBound(%q, %t): %v", tt.in, tt.endOfDay, err)
+			continue
+		}
+		if !got.Equal(tt.want) {
+			t.Errorf("ParseDateBound(%q, %t) = %s, want %s", tt.in, tt.endOfDay, got, tt.want)
+		}
+	}
+
+	for _, in := range []string{"yesterday", "2024-13-01", "-3d", "d", "3x", "2024/01/01"} {
+		if _, err := ParseDateBound(in, now, false); err == nil {
+			t.Errorf("ParseDateBound(%q) succeeded", in)
+		}
+	}
+}
+
+func TestParseDateBoundUsesLocalDays(t *testing.T) {
+	loc := time.FixedZone("UTC+2", 2*60*60)
+	now := time.Date(2024, 3, 15, 12, 0, 0, 0, loc)
+	got, err := ParseDateBound("2024-01-01", now, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2024, 1, 1, 0, 0, 0, 0, loc); !got.Equal(want) {
+		t.Errorf("got %s, want midnight in the local zone %s", got, want)
+	}
+}
+
+func TestPurgeDateRange(t *testing.T) {
+	t.Parallel()
+	s := newTestServer(t)
+	// Oldest first: 150 messages before the range, ending with one of the user's, then
+	// three in it and 150 after it, starting with one of the user's exactly at before.
+	s.GenerateMessages("10", other, 149, "theirs")
+	s.GenerateMessages("10", me, 1, "too old")
+	first := s.GenerateMessages("10", me, 1, "at after")
+	s.GenerateMessages("10", other, 1, "theirs")
+	last := s.GenerateMessages("10", me, 1, "in range")
+	atBefore := s.GenerateMessages("10", me, 1, "at before")
+	s.GenerateMessages("10", other, 149, "theirs")
+
+	p := newTestPurger(t, s)
+	p.SetDateRange(first[0].Time(), atBefore[0].Time())
+
+	var r recorder
+	if err := p.Purge(context.Background(), "10", r.push); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := s.Deleted(), []string{last[0].ID, first[0].ID}; !sameIDs(got, want) {
+		t.Errorf("deleted %v, want %v", got, want)
+	}
+	// The first page starts at before and already reaches past after, so it is the only one.
+	if n := s.Requests(discordtest.RouteMessages); n != 1 {
+		t.Errorf("%d message fetches, want 1", n)
+	}
+}
+
+func TestPurgeAfterOnly(t *testing.T) {
+	t.Parallel()
+	s := newTestServer(t)
+	s.GenerateMessages("10", me, 150, "too old")
+	mine := s.GenerateMessages("10", me, 2, "recent")
+	s.GenerateMessages("10", other, 150, "theirs")
+
+	p := newTestPurger(t, s)
+	p.SetDateRange(mine[0].Time(), time.Time{})
+
+	var r recorder
+	if err := p.Purge(context.Background(), "10", r.push); err != nil {
+		t.Fatal(err)
+	}
+	if got := s.Deleted(); !sameIDs(got, ids(mine)) {
+		t.Errorf("deleted %v, want %v", got, ids(mine))
+	}
+	// The second page reaches past after, the third with the rest of the old messages isn't fetched.
+	if n := s.Requests(discordtest.RouteMessages); n != 2 {
+		t.Errorf("%d message fetches, want 2", n)
+	}
+}
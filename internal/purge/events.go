@@ -0,0 +1,120 @@
+package purge
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+)
+
+// Event is the JSON form of an Update, one per line in an event stream.
+// Fields that don't apply to the event type are left out.
+type Event struct {
+	Time      time.Time `json:"time"`
+	Type      string    `json:"type"`
+	ChannelID string    `json:"channel_id,omitempty"`
+	GuildID   string    `json:"guild_id,omitempty"`
+	MessageID string    `json:"message_id,omitempty"`
+
+	Content    string  `json:"content,omitempty"`
+	Message    string  `json:"message,omitempty"`
+	RetryAfter float64 `json:"retry_after,omitempty"` // Seconds, like Discord's own field.
+
+	// matched
+	Timestamp   time.Time `json:"timestamp,omitzero"`
+	Attachments int       `json:"attachments,omitempty"`
+
+	// channel
+	Name  string `json:"name,omitempty"`
+	Index int    `json:"index,omitempty"`
+	Total int    `json:"total,omitempty"`
+
+	// done and channel_done
+	Channels  int       `json:"channels,omitempty"`
+	Deleted   *int      `json:"deleted,omitempty"`
+	Failed    *int      `json:"failed,omitempty"`
+	Throttled *int      `json:"throttled,omitempty"`
+	Matched   *int      `json:"matched,omitempty"`
+	Stopped   bool      `json:"stopped,omitempty"`
+	DryRun    bool      `json:"dry_run,omitempty"`
+	Oldest    time.Time `json:"oldest,omitzero"`
+	Newest    time.Time `json:"newest,omitzero"`
+}
+
+// NewEvent converts u. ok is false for values that aren't one of the Update types.
+func NewEvent(u Update, now time.Time) (e Event, ok bool) {
+	e.Time = now.UTC()
+
+	switch u := u.(type) {
+	case UpdateDeleted:
+		e.Type, e.ChannelID, e.MessageID, e.Content = "deleted", u.ChannelID, u.ID, u.Content
+	case UpdateFailed:
+		e.Type, e.ChannelID, e.MessageID, e.Message = "failed", u.ChannelID, u.MessageID, u.Message
+	case UpdateRateLimited:
+		e.Type, e.ChannelID, e.RetryAfter = "rate_limited", u.ChannelID, u.Timeout.Seconds()
+	case UpdateInfo:
+		e.Type, e.ChannelID, e.Message = "info", u.ChannelID, u.Message
+	case UpdateMatched:
+		e.Type, e.ChannelID, e.MessageID, e.Content = "matched", u.ChannelID, u.ID, u.Content
+		e.Timestamp, e.Attachments = u.Timestamp, u.Attachments
+	case UpdatePaused:
+		e.Type, e.ChannelID = "paused", u.ChannelID
+	case UpdateResumed:
+		e.Type, e.ChannelID = "resumed", u.ChannelID
+	case UpdateChannel:
+		e.Type, e.ChannelID = "channel", u.ChannelID
+		e.Name, e.Index, e.Total = u.Name, u.Index, u.Total
+	case UpdateChannelDone:
+		e.setDone(UpdateDone(u))
+		e.Type = "channel_done"
+	case UpdateDone:
+		e.setDone(u)
+	default:
+		return e, false
+	}
+	return e, true
+}
+
+func (e *Event) setDone(u UpdateDone) {
+	e.Type, e.ChannelID, e.GuildID, e.Channels = "done", u.ChannelID, u.GuildID, u.Channels
+	e.Deleted, e.Failed, e.Throttled = &u.Deleted, &u.Failed, &u.Throttled
+	e.Stopped, e.DryRun = u.Stopped, u.DryRun
+	if u.DryRun {
+		e.Matched, e.Attachments = &u.Matched, u.Attachments
+		e.Oldest, e.Newest = u.Oldest, u.Newest
+	}
+}
+
+// EventEncoder writes updates as JSON Lines. It is safe for concurrent use.
+type EventEncoder struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+func NewEventEncoder(w io.Writer) *EventEncoder {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	return &EventEncoder{enc: enc}
+}
+
+// Encode writes u as one line, skipping values that aren't updates.
+func (e *EventEncoder) Encode(u Update) error {
+	ev, ok := NewEvent(u, time.Now())
+	if !ok {
+		return nil
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.enc.Encode(ev)
+}
+
+// Tee returns a push func that encodes every update and then passes it to next,
+// which may be nil. Encoding errors are dropped so they can't interrupt a purge.
+func (e *EventEncoder) Tee(next func(Update)) func(Update) {
+	return func(u Update) {
+		e.Encode(u)
+		if next != nil {
+			next(u)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package purge
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+// decodeEvents reads every line of b into a map, so left out fields can be told apart from zero ones.
+func decodeEvents(t *testing.T, b *bytes.Buffer) []map[string]any {
+	t.Helper()
+	var events []map[string]any
+	sc := bufio.NewScanner(b)
+	for sc.Scan() {
+		var e map[string]any
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			t.Fatalf("%q: %v", sc.Text(), err)
+		}
+		events = append(events, e)
+	}
+	return events
+}
+
+func TestEventFields(t *testing.T) {
+	tests := []struct {
+		update Update
+		want   map[string]any
+	}{
+		{UpdateDeleted{ChannelID: "10", ID: "100", Content: "<hi>"},
+			map[string]any{"type": "deleted", "channel_id": "10", "message_id": "100", "content": "<hi>"}},
+		{UpdateFailed{ChannelID: "10", MessageID: "100", Message: "nope"},
+			map[string]any{"type": "failed", "channel_id": "10", "message_id": "100", "message": "nope"}},
+		{UpdateRateLimited{ChannelID: "10", Timeout: 1500 * time.Millisecond},
+			map[string]any{"type": "rate_limited", "channel_id": "10", "retry_after": 1.5}},
+		{UpdateInfo{Message: "hello"},
+			map[string]any{"type": "info", "message": "hello"}},
+		{UpdatePaused{ChannelID: "10"}, map[string]any{"type": "paused", "channel_id": "10"}},
+		{UpdateResumed{ChannelID: "10"}, map[string]any{"type": "resumed", "channel_id": "10"}},
+		{UpdateChannel{ChannelID: "20", Name: "general", Index: 2, Total: 5},
+			map[string]any{"type": "channel", "channel_id": "20", "name": "general", "index": 2.0, "total": 5.0}},
+		{UpdateChannelDone{ChannelID: "20", Deleted: 3},
+			map[string]any{"type": "channel_done", "channel_id": "20", "deleted": 3.0, "failed": 0.0, "throttled": 0.0}},
+		{UpdateDone{ChannelID: "10", Deleted: 4, Failed: 1, Throttled: 2, Stopped: true},
+			map[string]any{"type": "done", "channel_id": "10", "deleted": 4.0, "failed": 1.0, "throttled": 2.0, "stopped": true}},
+		{UpdateDone{GuildID: "5", Channels: 6, Deleted: 7},
+			map[string]any{"type": "done", "guild_id": "5", "channels": 6.0, "deleted": 7.0, "failed": 0.0, "throttled": 0.0}},
+		{UpdateDone{ChannelID: "10", DryRun: true, Matched: 2, Attachments: 1,
+			Oldest: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), Newest: time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)},
+			map[string]any{"type": "done", "channel_id": "10", "dry_run": true, "matched": 2.0, "attachments": 1.0,
+				"deleted": 0.0, "failed": 0.0, "throttled": 0.0, "oldest": "2024-01-01T00:00:00Z", "newest": "2024-02-01T00:00:00Z"}},
+	}
+
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.FixedZone("", 3600))
+	for _, tt := range tests {
+		var b bytes.Buffer
+		ev, ok := NewEvent(tt.update, now)
+		if !ok {
+			t.Errorf("NewEvent(%T) not ok", tt.update)
+			continue
+		}
+		enc := json.NewEncoder(&b)
+		enc.SetEscapeHTML(false)
+		if err := enc.Encode(ev); err != nil {
+			t.Fatal(err)
+		}
+		got := decodeEvents(t, &b)[0]
+
+		if got["time"] != "2025-01-01T11:00:00Z" {
+			t.Errorf("%T: time = %v, want it in UTC", tt.update, got["time"])
+		}
+		delete(got, "time")
+		if len(got) != len(tt.want) {
+			t.Errorf("%T: event %v, want %v", tt.update, got, tt.want)
+			continue
+		}
+		for k, v := range tt.want {
+			if got[k] != v {
+				t.Errorf("%T: %s = %v, want %v", tt.update, k, got[k], v)
+			}
+		}
+	}
+
+	if _, ok := NewEvent("not an update", now); ok {
+		t.Error("NewEvent of a string is ok")
+	}
+}
+
+func TestEventEncoderTee(t *testing.T) {
+	var b bytes.Buffer
+	enc := NewEventEncoder(&b)
+
+	var passed []Update
+	push := enc.Tee(func(u Update) {
+		// Each update is written before it is passed on.
+		if n := len(decodeEvents(t, bytes.NewBuffer(b.Bytes()))); n != len(passed)+1 {
+			t.Errorf("%d events written when passing on update %d", n, len(passed)+1)
+		}
+		passed = append(passed, u)
+	})
+	push(UpdateInfo{Message: "start"})
+	push(UpdateDeleted{ChannelID: "10", ID: "100"})
+	push(UpdateDone{ChannelID: "10", Deleted: 1})
+
+	events := decodeEvents(t, &b)
+	if len(events) != 3 || len(passed) != 3 {
+		t.Fatalf("%d events written, %d updates passed on, want 3 each", len(events), len(passed))
+	}
+	for i, typ := range []string{"info", "deleted", "done"} {
+		if events[i]["type"] != typ {
+			t.Errorf("event %d is %v, want %s", i, events[i]["type"], typ)
+		}
+	}
+
+	// Without a next func, Tee only encodes.
+	enc.Tee(nil)(UpdatePaused{})
+	if n := len(decodeEvents(t, &b)); n != 1 {
+		t.Errorf("%d events after Tee(nil), want 1", n)
+	}
+}
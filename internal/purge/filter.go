@@ -0,0 +1,136 @@
+package purge
+
+import (
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+
+	"purge/internal/discord"
+)
+
+// Filter decides whether a message is purged. Implement it to plug custom selection
+// rules into a Purger with AddFilter; ParseFilter builds one from an expression.
+type Filter interface {
+	Match(m discord.Message) bool
+}
+
+// FilterFunc lets a plain function be used as a Filter.
+type FilterFunc func(m discord.Message) bool
+
+func (f FilterFunc) Match(m discord.Message) bool { return f(m) }
+
+// Keywords matches messages containing any of the keywords, ignoring case. It is what
+// a plain filter setting parses to, and what plain text in a filter expression matches.
+// An empty list matches everything, and so does an empty keyword.
+type Keywords []string
+
+func (k Keywords) Match(m discord.Message) bool {
+	if len(k) == 0 {
+		return true
+	}
+	lc := strings.ToLower(m.Content)
+	for _, f := range k {
+		if strings.Contains(lc, strings.ToLower(f)) {
+			return true
+		}
+	}
+	return false
+}
+
+type andFilter struct{ left, right Filter }
+
+func (e andFilter) Match(m discord.Message) bool { return e.left.Match(m) && e.right.Match(m) }
+
+type orFilter struct{ left, right Filter }
+
+func (e orFilter) Match(m discord.Message) bool { return e.left.Match(m) || e.right.Match(m) }
+
+type notFilter struct{ inner Filter }
+
+func (e notFilter) Match(m discord.Message) bool { return !e.inner.Match(m) }
+
+// wordFilter matches text only as a whole word, so "cat" doesn't match "concatenate".
+type wordFilter struct{ text string }
+
+func (e wordFilter) Match(m discord.Message) bool {
+	content := strings.ToLower(m.Content)
+	for i := 0; ; {
+		j := strings.Index(content[i:], e.text)
+		if j < 0 {
+			return false
+		}
+		start, end := i+j, i+j+len(e.text)
+		if isWordBoundary(content, start-1) && isWordBoundary(content, end) {
+			return true
+		}
+		i = start + 1
+	}
+}
+
+func isWordBoundary(s string, i int) bool {
+	if i < 0 || i >= len(s) {
+		return true
+	}
+	r := rune(s[i])
+	return !(unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r >= 0x80)
+}
+
+type regexFilter struct{ re *regexp.Regexp }
+
+func (e regexFilter) Match(m discord.Message) bool { return e.re.MatchString(m.Content) }
+
+var linkRe = regexp.MustCompile(`(?i)\bhttps?://\S+`)
+var mentionRe = regexp.MustCompile(`<@[!&]?\d+>|@everyone|@here`)
+
+type hasFilter struct{ what string }
+
+func (e hasFilter) Match(m discord.Message) bool {
+	switch e.what {
+	case "attachment":
+		return len(m.Attachments) > 0
+	case "link":
+		return linkRe.MatchString(m.Content)
+	case "mention":
+		return len(m.Mentions) > 0 || m.MentionEveryone || mentionRe.MatchString(m.Content)
+	}
+	return false
+}
+
+// lengthFilter compares the content length in characters.
+type lengthFilter struct {
+	op string
+	n  int
+}
+
+func (e lengthFilter) Match(m discord.Message) bool {
+	l := len([]rune(m.Content))
+	switch e.op {
+	case "<":
+		return l < e.n
+	case "<=":
+		return l <= e.n
+	case ">":
+		return l > e.n
+	case ">=":
+		return l >= e.n
+	}
+	return l == e.n
+}
+
+type typeFilter struct{ t int }
+
+func (e typeFilter) Match(m discord.Message) bool { return m.Type == e.t }
+
+// dateFilter matches messages sent before t, or with after set, at or after t.
+type dateFilter struct {
+	t     time.Time
+	after bool
+}
+
+func (e dateFilter) Match(m discord.Message) bool {
+	if e.after {
+		return !m.Time().Before(e.t)
+	}
+	return m.Time().Before(e.t)
+}
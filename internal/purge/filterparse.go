@@ -0,0 +1,391 @@
+package purge
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"purge/internal/discord"
+)
+
+// ExprPrefix marks a filter setting as a filter expression. Without it, a setting is
+// a comma-separated list of keywords like it has always been.
+const ExprPrefix = "expr:"
+
+// ParseFilter parses a filter setting, as typed in the settings or passed to -filter.
+// An empty setting returns a nil Filter, which selects every message.
+//
+// A plain setting is a comma-separated list of keywords, see Keywords: "DO NOT DELETE"
+// selects messages containing exactly that text, and "gm, gn" those containing either.
+// A setting starting with "expr:" is a filter expression instead, see parseExpr.
+func ParseFilter(s string) (Filter, error) {
+	if expr, ok := strings.CutPrefix(strings.TrimSpace(s), ExprPrefix); ok {
+		return parseExpr(expr)
+	}
+	return parseKeywords(s), nil
+}
+
+// parseKeywords splits a keyword list on commas and trims the spaces around each keyword.
+func parseKeywords(s string) Filter {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil
+	}
+	k := strings.Split(s, ",")
+	for i := range k {
+		k[i] = strings.TrimSpace(k[i])
+	}
+	return Keywords(k)
+}
+
+// parseExpr parses a filter expression. An empty expression returns a nil Filter.
+//
+// Terms:
+//
+//	hello, "hello world"   content contains the text, ignoring case
+//	word:cat               content contains cat as a whole word
+//	regex:"^gm\b"          content matches the Go regular expression
+//	has:attachment         also has:link and has:mention
+//	len:>100               content length; <, <=, >, >= or a plain number for equality
+//	type:reply             message type, by name (default, reply) or number
+//	before:2024-06-30      sent before the date; after: is sent on or after it.
+//	                       Dates take the same forms as the settings, e.g. 30d.
+//
+// Terms combine with NOT (or a leading -), AND and OR, in that order of precedence,
+// and parentheses. Terms next to each other are ANDed, and a comma is an OR. Example:
+//
+//	has:attachment AND NOT "keep"
+func parseExpr(s string) (Filter, error) {
+	toks, err := lexFilter(s)
+	if err != nil {
+		return nil, err
+	}
+
+	ps := &filterParser{toks: toks, now: time.Now()}
+	ps.skipCommas()
+	if ps.peek().kind == tokEOF {
+		return nil, nil
+	}
+
+	e, err := ps.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if t := ps.peek(); t.kind != tokEOF {
+		return nil, fmt.Errorf("unexpected %s at position %d", t, t.pos)
+	}
+	return e, nil
+}
+
+type tokKind int
+
+const (
+	tokEOF tokKind = iota
+	tokTerm
+	tokAnd
+	tokOr
+	tokComma
+	tokNot
+	tokLParen
+	tokRParen
+)
+
+type filterToken struct {
+	kind   tokKind
+	key    string // For terms: "" for plain text, otherwise the part before the colon.
+	value  string
+	quoted bool
+	pos    int
+}
+
+func (t filterToken) String() string {
+	switch t.kind {
+	case tokEOF:
+		return "end of filter"
+	case tokTerm:
+		if t.key != "" {
+			return fmt.Sprintf("%q", t.key+":"+t.value)
+		}
+		return fmt.Sprintf("%q", t.value)
+	case tokAnd:
+		return "AND"
+	case tokOr:
+		return "OR"
+	case tokComma:
+		return `","`
+	case tokNot:
+		return "NOT"
+	case tokLParen:
+		return `"("`
+	}
+	return `")"`
+}
+
+func lexFilter(s string) ([]filterToken, error) {
+	var toks []filterToken
+	i := 0
+	for i < len(s) {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n':
+			i++
+		case c == '(':
+			toks = append(toks, filterToken{kind: tokLParen, pos: i})
+			i++
+		case c == ')':
+			toks = append(toks, filterToken{kind: tokRParen, pos: i})
+			i++
+		case c == ',':
+			toks = append(toks, filterToken{kind: tokComma, pos: i})
+			i++
+		case c == '-' && i+1 < len(s) && s[i+1] != ' ':
+			toks = append(toks, filterToken{kind: tokNot, pos: i})
+			i++
+		case c == '"':
+			v, n, err := lexQuoted(s[i:])
+			if err != nil {
+				return nil, fmt.Errorf("%w at position %d", err, i)
+			}
+			toks = append(toks, filterToken{kind: tokTerm, value: v, quoted: true, pos: i})
+			i += n
+		default:
+			start := i
+			for i < len(s) && !strings.ContainsRune(" \t\n(),\":", rune(s[i])) {
+				i++
+			}
+			word := s[start:i]
+
+			if i < len(s) && s[i] == ':' && word != "" {
+				t := filterToken{kind: tokTerm, key: strings.ToLower(word), pos: start}
+				i++
+				if i < len(s) && s[i] == '"' {
+					v, n, err := lexQuoted(s[i:])
+					if err != nil {
+						return nil, fmt.Errorf("%w at position %d", err, i)
+					}
+					t.value, t.quoted = v, true
+					i += n
+				} else {
+					vs := i
+					for i < len(s) && !strings.ContainsRune(" \t\n(),", rune(s[i])) {
+						i++
+					}
+					t.value = s[vs:i]
+				}
+				toks = append(toks, t)
+				continue
+			}
+			if word == "" {
+				// A lone colon, treat it as text.
+				word = s[i : i+1]
+				i++
+			}
+
+			switch word {
+			case "AND":
+				toks = append(toks, filterToken{kind: tokAnd, pos: start})
+			case "OR":
+				toks = append(toks, filterToken{kind: tokOr, pos: start})
+			case "NOT":
+				toks = append(toks, filterToken{kind: tokNot, pos: start})
+			default:
+				toks = append(toks, filterToken{kind: tokTerm, value: word, pos: start})
+			}
+		}
+	}
+	return append(toks, filterToken{kind: tokEOF, pos: len(s)}), nil
+}
+
+// lexQuoted reads a "quoted" string with \" and \\ escapes, returning it and the bytes consumed.
+func lexQuoted(s string) (string, int, error) {
+	var b strings.Builder
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			if i+1 < len(s) && (s[i+1] == '"' || s[i+1] == '\\') {
+				i++
+			}
+			b.WriteByte(s[i])
+		case '"':
+			return b.String(), i + 1, nil
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return "", 0, fmt.Errorf("unterminated quote")
+}
+
+type filterParser struct {
+	toks []filterToken
+	i    int
+	now  time.Time
+}
+
+func (ps *filterParser) peek() filterToken {
+	return ps.toks[ps.i]
+}
+
+func (ps *filterParser) next() filterToken {
+	t := ps.toks[ps.i]
+	if t.kind != tokEOF {
+		ps.i++
+	}
+	return t
+}
+
+// Stray commas are ignored, "a,,b," is the same as "a,b".
+func (ps *filterParser) skipCommas() {
+	for ps.peek().kind == tokComma {
+		ps.i++
+	}
+}
+
+func (ps *filterParser) parseOr() (Filter, error) {
+	left, err := ps.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		switch ps.peek().kind {
+		case tokOr:
+			ps.next()
+		case tokComma:
+			ps.skipCommas()
+			if k := ps.peek().kind; k == tokEOF || k == tokRParen {
+				return left, nil
+			}
+		default:
+			return left, nil
+		}
+		right, err := ps.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = orFilter{left, right}
+	}
+}
+
+func (ps *filterParser) parseAnd() (Filter, error) {
+	left, err := ps.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		switch ps.peek().kind {
+		case tokAnd:
+			ps.next()
+		case tokTerm, tokNot, tokLParen:
+		default:
+			return left, nil
+		}
+		right, err := ps.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = andFilter{left, right}
+	}
+}
+
+func (ps *filterParser) parseUnary() (Filter, error) {
+	if ps.peek().kind == tokNot {
+		ps.next()
+		inner, err := ps.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return notFilter{inner}, nil
+	}
+	return ps.parsePrimary()
+}
+
+func (ps *filterParser) parsePrimary() (Filter, error) {
+	t := ps.next()
+	switch t.kind {
+	case tokLParen:
+		e, err := ps.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if r := ps.next(); r.kind != tokRParen {
+			return nil, fmt.Errorf("expected \")\" at position %d, got %s", r.pos, r)
+		}
+		return e, nil
+	case tokTerm:
+		e, err := ps.term(t)
+		if err != nil {
+			return nil, fmt.Errorf("%w at position %d", err, t.pos)
+		}
+		return e, nil
+	}
+	return nil, fmt.Errorf("unexpected %s at position %d", t, t.pos)
+}
+
+var lengthRe = regexp.MustCompile(`^(<=|>=|<|>|=)?(\d+)$`)
+
+func (ps *filterParser) term(t filterToken) (Filter, error) {
+	v := t.value
+	switch t.key {
+	case "":
+		return Keywords{v}, nil
+
+	case "word":
+		if v == "" {
+			return nil, fmt.Errorf("word: needs a value")
+		}
+		return wordFilter{strings.ToLower(v)}, nil
+
+	case "regex", "re":
+		re, err := regexp.Compile(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regex: %w", err)
+		}
+		return regexFilter{re}, nil
+
+	case "has":
+		switch strings.ToLower(v) {
+		case "attachment", "attachments", "file":
+			return hasFilter{"attachment"}, nil
+		case "link", "links":
+			return hasFilter{"link"}, nil
+		case "mention", "mentions":
+			return hasFilter{"mention"}, nil
+		}
+		return nil, fmt.Errorf("unknown has:%s, expected attachment, link or mention", v)
+
+	case "len", "length":
+		sm := lengthRe.FindStringSubmatch(v)
+		if sm == nil {
+			return nil, fmt.Errorf("invalid len:%s, expected e.g. len:>100", v)
+		}
+		n, _ := strconv.Atoi(sm[2])
+		return lengthFilter{op: sm[1], n: n}, nil
+
+	case "type":
+		switch strings.ToLower(v) {
+		case "default":
+			return typeFilter{discord.MessageTypeDefault}, nil
+		case "reply":
+			return typeFilter{discord.MessageTypeReply}, nil
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, fmt.Errorf("unknown type:%s, expected default, reply or a number", v)
+		}
+		return typeFilter{n}, nil
+
+	case "before", "after":
+		d, err := ParseDateBound(v, ps.now, false)
+		if err != nil {
+			return nil, err
+		}
+		if d.IsZero() {
+			return nil, fmt.Errorf("%s: needs a date", t.key)
+		}
+		return dateFilter{t: d, after: t.key == "after"}, nil
+	}
+
+	// Not a known key, e.g. "note: buy milk" or a URL. Match it as plain text.
+	return Keywords{t.key + ":" + v}, nil
+}
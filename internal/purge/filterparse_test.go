@@ -0,0 +1,172 @@
+package purge
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"purge/internal/discord"
+)
+
+// legacyMatch is how filters worked before expressions: the settings split the field on
+// commas and trimmed each part, and a message matched if it contained any part.
+func legacyMatch(setting, content string) bool {
+	setting = strings.TrimSpace(setting)
+	if setting == "" {
+		return true
+	}
+	lc := strings.ToLower(content)
+	for _, f := range strings.Split(setting, ",") {
+		if strings.Contains(lc, strings.ToLower(strings.TrimSpace(f))) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestKeywordListsSelectWhatTheyUsedTo(t *testing.T) {
+	settings := []string{
+		"",
+		"DO NOT DELETE",
+		"-_-",
+		"i'm out",
+		"hello, world",
+		" gm ,gn ",
+		"a,,b",
+		"trailing,",
+		"has:link",
+		"word:cat",
+		`"quoted"`,
+		"(nested) OR stuff",
+		"NOT this",
+		"ÄÖÜ",
+	}
+	contents := []string{
+		"",
+		"do",
+		"Do not delete this",
+		"DO NOT DELETE",
+		"please delete",
+		"-_-",
+		"_",
+		"i'm out of here",
+		"out",
+		"hello there",
+		"world",
+		"gm everyone",
+		"GN",
+		"b",
+		"has:link in text",
+		"https://example.com",
+		"word:cat",
+		"concatenate",
+		`she said "quoted"`,
+		"(nested) or stuff",
+		"not this one",
+		"this",
+		"äöü",
+	}
+
+	for _, setting := range settings {
+		f, err := ParseFilter(setting)
+		if err != nil {
+			t.Errorf("ParseFilter(%q): %v", setting, err)
+			continue
+		}
+		for _, content := range contents {
+			got := f == nil || f.Match(discord.Message{Content: content})
+			if want := legacyMatch(setting, content); got != want {
+				t.Errorf("filter %q on %q = %t, used to be %t", setting, content, got, want)
+			}
+		}
+	}
+}
+
+func TestParseExpr(t *testing.T) {
+	now := time.Now()
+	msg := func(content string) discord.Message { return discord.Message{Content: content} }
+	sent := func(t time.Time) discord.Message {
+		return discord.Message{ID: discord.SnowflakeFromTime(t), Timestamp: t.Format(time.RFC3339)}
+	}
+
+	tests := []struct {
+		expr string
+		m    discord.Message
+		want bool
+	}{
+		{"hello", msg("Hello there"), true},
+		{"hello", msg("bye"), false},
+		{`"hello world"`, msg("oh hello world!"), true},
+		{`"hello world"`, msg("hello, world"), false},
+		{"DO NOT DELETE", msg("do it"), true},
+		{"DO NOT DELETE", msg("do delete"), false},
+		{"a b", msg("b then a"), true},
+		{"a AND b", msg("a only"), false},
+		{"a OR b", msg("b only"), true},
+		{"a, b", msg("b only"), true},
+		{"NOT keep", msg("keep this"), false},
+		{"-keep", msg("toss this"), true},
+		{"a OR b AND c", msg("a"), true},
+		{"a OR b AND c", msg("b"), false},
+		{"(a OR b) AND c", msg("a"), false},
+		{"(a OR b) AND c", msg("b c"), true},
+		{"word:cat", msg("a cat sat"), true},
+		{"word:cat", msg("concatenate"), false},
+		{`regex:"^gm\b"`, msg("gm all"), true},
+		{`regex:"^gm\b"`, msg("gmail"), false},
+		{"has:link", msg("see https://example.com"), true},
+		{"has:link", msg("no link"), false},
+		{"has:attachment", discord.Message{Attachments: []discord.Attachment{{ID: "1"}}}, true},
+		{"has:mention", msg("hi <@123>"), true},
+		{"len:>5", msg("123456"), true},
+		{"len:>5", msg("12345"), false},
+		{"len:0", msg(""), true},
+		{"type:reply", discord.Message{Type: discord.MessageTypeReply}, true},
+		{"type:19", discord.Message{Type: discord.MessageTypeReply}, true},
+		{"type:default", discord.Message{Type: discord.MessageTypeReply}, false},
+		{"before:2d", sent(now.Add(-72 * time.Hour)), true},
+		{"before:2d", sent(now.Add(-time.Hour)), false},
+		{"after:2d", sent(now.Add(-time.Hour)), true},
+		{"note:milk", msg("note:milk"), true},
+		{"https://example.com", msg("see https://example.com"), true},
+		{`has:attachment AND NOT "keep"`, discord.Message{Content: "keep me", Attachments: []discord.Attachment{{ID: "1"}}}, false},
+	}
+	for _, tt := range tests {
+		f, err := ParseFilter(ExprPrefix + " " + tt.expr)
+		if err != nil {
+			t.Errorf("ParseFilter(%q): %v", tt.expr, err)
+			continue
+		}
+		if got := f.Match(tt.m); got != tt.want {
+			t.Errorf("%q on %+v = %t, want %t", tt.expr, tt.m, got, tt.want)
+		}
+	}
+}
+
+func TestParseExprEmpty(t *testing.T) {
+	for _, s := range []string{"expr:", "expr:   ", "expr: ,,"} {
+		if f, err := ParseFilter(s); f != nil || err != nil {
+			t.Errorf("ParseFilter(%q) = %v, %v, want nil, nil", s, f, err)
+		}
+	}
+}
+
+func TestParseExprErrors(t *testing.T) {
+	for _, expr := range []string{
+		`"unterminated`,
+		"a AND",
+		"(a OR b",
+		"a)",
+		"has:nothing",
+		"len:big",
+		"type:weird",
+		"regex:(",
+		"before:someday",
+		"word:",
+		"NOT",
+	} {
+		if _, err := ParseFilter("expr:" + expr); err == nil {
+			t.Errorf("ParseFilter(%q) succeeded", "expr:"+expr)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package purge
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"purge/internal/discord"
+)
+
+// GuildChannels lists everything in guildID that can hold the user's messages, in sidebar
+// order: text, announcement and voice channels, each followed by its active and archived
+// threads. Forum and media posts follow their forum. Rate limits are waited out.
+func (p *Purger) GuildChannels(ctx context.Context, guildID string, push func(Update)) ([]discord.Channel, error) {
+	var channels []discord.Channel
+	err := p.retryRateLimited(ctx, push, func() (err error) {
+		channels, err = p.client.FetchGuildChannels(ctx, guildID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var threads []discord.Channel
+	err = p.retryRateLimited(ctx, push, func() (err error) {
+		threads, err = p.client.FetchActiveThreads(ctx, guildID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, ch := range channels {
+		switch ch.Type {
+		case discord.ChannelTypeGuildText, discord.ChannelTypeGuildAnnouncement,
+			discord.ChannelTypeGuildForum, discord.ChannelTypeGuildMedia:
+		default:
+			continue
+		}
+
+		// The client retries each page itself, the rate limiter spaces the requests out.
+		archived, err := p.client.FetchArchivedThreads(ctx, ch.ID)
+		if isForbidden(err) {
+			// No access to the parent means no access to its threads either.
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		threads = append(threads, archived...)
+	}
+
+	byParent := make(map[string][]discord.Channel)
+	seen := make(map[string]bool)
+	for _, t := range threads {
+		if !seen[t.ID] {
+			seen[t.ID] = true
+			byParent[t.ParentID] = append(byParent[t.ParentID], t)
+		}
+	}
+
+	var all []discord.Channel
+	for _, ch := range discord.SortChannels(channels) {
+		if ch.HasMessages() {
+			all = append(all, ch)
+		}
+		all = append(all, byParent[ch.ID]...)
+	}
+	return all, nil
+}
+
+// PurgeGuild purges the user's messages from every channel and thread in guildID, one
+// after another, see GuildChannels. Each channel starts with UpdateChannel and ends with
+// UpdateChannelDone; the final UpdateDone adds them all up. Channels the user can't
+// read are skipped, and saved checkpoints are resumed automatically.
+func (p *Purger) PurgeGuild(ctx context.Context, guildID string, push func(Update)) error {
+	total := UpdateDone{GuildID: guildID, DryRun: p.dryRun}
+
+	push(UpdateInfo{Message: "Listing channels and threads..."})
+	channels, err := p.GuildChannels(ctx, guildID, push)
+	if ctx.Err() != nil {
+		total.Stopped = true
+		push(total)
+		return ctx.Err()
+	}
+	if err != nil {
+		push(UpdateFailed{Message: err.Error()})
+		return err
+	}
+	push(UpdateInfo{Message: fmt.Sprintf("Found %d channels and threads", len(channels))})
+
+	jobs := make([]Job, len(channels))
+	for i, ch := range channels {
+		jobs[i] = Job{ChannelID: ch.ID, Name: ch.Name}
+	}
+	return p.runQueue(ctx, jobs, total, push)
+}
+
+func (d *UpdateDone) add(c UpdateDone) {
+	d.Channels++
+	d.Deleted += c.Deleted
+	d.Failed += c.Failed
+	d.Throttled += c.Throttled
+	d.Matched += c.Matched
+	d.Attachments += c.Attachments
+	if !c.Oldest.IsZero() && (d.Oldest.IsZero() || c.Oldest.Before(d.Oldest)) {
+		d.Oldest = c.Oldest
+	}
+	if c.Newest.After(d.Newest) {
+		d.Newest = c.Newest
+	}
+}
+
+// retryRateLimited calls fn until it returns something other than a discord.RateLimitError.
+func (p *Purger) retryRateLimited(ctx context.Context, push func(Update), fn func() error) error {
+	for {
+		err := fn()
+		var rl discord.RateLimitError
+		if !errors.As(err, &rl) {
+			return err
+		}
+		push(UpdateRateLimited{Timeout: rl.RetryAfter})
+		if err := p.handleRateLimit(ctx, rl.RetryAfter); err != nil {
+			return err
+		}
+	}
+}
+
+// isForbidden reports whether err is Discord refusing access, e.g. to a channel the user can't read.
+func isForbidden(err error) bool {
+	return hasStatus(err, http.StatusForbidden)
+}
+
+// hasStatus reports whether err is an API response with the given status. Only the
+// typed error counts: IDs in the text of other errors can contain any digits.
+func hasStatus(err error, status int) bool {
+	var he *discord.HTTPError
+	return errors.As(err, &he) && he.Status == status
+}
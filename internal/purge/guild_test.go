@@ -0,0 +1,127 @@
+package purge
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"purge/internal/discord"
+	"purge/internal/discord/discordtest"
+)
+
+// newTestGuild adds guild "5": text channels "20" and "21" in category "19", where "21"
+// can't be read, and a voice channel "23". "20" has an active thread "30", an archived
+// public thread "31" and an archived private one "32"; "21" has an archived thread "33".
+func newTestGuild(s *discordtest.Server) {
+	archived := &discord.ThreadMetadata{Archived: true, ArchiveTimestamp: "2024-01-01T00:00:00Z"}
+	s.AddGuild(discord.Guild{ID: "5", Name: "guild"})
+	for _, ch := range []discord.Channel{
+		{ID: "19", Type: discord.ChannelTypeGuildCategory, Name: "text"},
+		{ID: "21", Type: discord.ChannelTypeGuildText, Name: "secret", ParentID: "19", Position: 2},
+		{ID: "20", Type: discord.ChannelTypeGuildText, Name: "general", ParentID: "19", Position: 1},
+		{ID: "23", Type: discord.ChannelTypeGuildVoice, Name: "voice", Position: 0},
+		{ID: "30", Type: discord.ChannelTypePublicThread, Name: "active", ParentID: "20"},
+		{ID: "31", Type: discord.ChannelTypePublicThread, Name: "old", ParentID: "20", ThreadMetadata: archived},
+		{ID: "32", Type: discord.ChannelTypePrivateThread, Name: "private", ParentID: "20", ThreadMetadata: archived},
+		{ID: "33", Type: discord.ChannelTypePublicThread, Name: "hidden", ParentID: "21", ThreadMetadata: archived},
+	} {
+		ch.GuildID = "5"
+		s.AddChannel(ch)
+	}
+	s.Deny("21")
+}
+
+func channelIDs(channels []discord.Channel) []string {
+	out := make([]string, len(channels))
+	for i, ch := range channels {
+		out[i] = ch.ID
+	}
+	return out
+}
+
+func TestGuildChannels(t *testing.T) {
+	t.Parallel()
+	s := newTestServer(t)
+	newTestGuild(s)
+	p := newTestPurger(t, s)
+
+	var r recorder
+	channels, err := p.GuildChannels(context.Background(), "5", r.push)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The voice channel is uncategorised, so it comes first. The threads of "21" can't be listed.
+	want := []string{"23", "20", "30", "31", "32", "21"}
+	got := channelIDs(channels)
+	if len(got) != len(want) {
+		t.Fatalf("channels = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("channels = %v, want %v", got, want)
+		}
+	}
+}
+
+func TestGuildChannelsWaitsOutRateLimits(t *testing.T) {
+	t.Parallel()
+	s := newTestServer(t)
+	newTestGuild(s)
+	s.ScriptRateLimit(discordtest.RouteGuildChannels, 2, discordtest.RateLimit{RetryAfter: time.Millisecond})
+	s.ScriptRateLimit(discordtest.RouteThreads, 2, discordtest.RateLimit{RetryAfter: time.Millisecond})
+	p := newTestPurger(t, s)
+
+	var r recorder
+	channels, err := p.GuildChannels(context.Background(), "5", r.push)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(channels); n != 6 {
+		t.Errorf("%d channels, want 6", n)
+	}
+	if n := s.Requests(discordtest.RouteGuildChannels); n != 3 {
+		t.Errorf("%d channel list requests, want 3", n)
+	}
+}
+
+func TestPurgeGuild(t *testing.T) {
+	t.Parallel()
+	s := newTestServer(t)
+	newTestGuild(s)
+	var mine []discord.Message
+	for _, id := range []string{"20", "30", "31", "32"} {
+		mine = append(mine, s.GenerateMessages(id, me, 2, "mine")...)
+		s.GenerateMessages(id, other, 3, "theirs")
+	}
+	s.GenerateMessages("21", me, 2, "mine")
+	p := newTestPurger(t, s)
+
+	var r recorder
+	if err := p.PurgeGuild(context.Background(), "5", r.push); err != nil {
+		t.Fatal(err)
+	}
+	if got := s.Deleted(); !sameIDs(got, ids(mine)) {
+		t.Errorf("deleted %v, want %v", got, ids(mine))
+	}
+
+	var started, finished []string
+	for _, u := range r.updates {
+		switch u := u.(type) {
+		case UpdateChannel:
+			started = append(started, u.ChannelID)
+		case UpdateChannelDone:
+			finished = append(finished, u.ChannelID)
+		}
+	}
+	if want := []string{"23", "20", "30", "31", "32", "21"}; !sameIDs(started, want) {
+		t.Errorf("started %v, want %v", started, want)
+	}
+	// The unreadable channel is skipped without an UpdateChannelDone.
+	if want := []string{"23", "20", "30", "31", "32"}; !sameIDs(finished, want) {
+		t.Errorf("finished %v, want %v", finished, want)
+	}
+	d := r.done(t)
+	if d.GuildID != "5" || d.Channels != 5 || d.Deleted != len(mine) || d.Failed != 0 || d.Stopped {
+		t.Errorf("done = %+v, want 5 channels and %d deleted", d, len(mine))
+	}
+}
@@ -0,0 +1,542 @@
+package purge
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"purge/internal/discord"
+	"purge/internal/discord/discordtest"
+)
+
+var (
+	me    = discord.Author{ID: "1", Username: "me"}
+	other = discord.Author{ID: "2", Username: "bob"}
+)
+
+// newTestServer starts a fake API with one DM, channel "10".
+func newTestServer(t *testing.T) *discordtest.Server {
+	t.Helper()
+	s := discordtest.NewServer("tok", discord.Profile{ID: me.ID, Username: me.Username})
+	t.Cleanup(s.Close)
+	s.AddChannel(discord.Channel{ID: "10", Type: discord.ChannelTypeDM, Recipients: []discord.User{{ID: other.ID, Username: other.Username}}})
+	return s
+}
+
+// newTestPurger returns a purger for s with the shortest delays. The client's rate limiter
+// is off, so 429s reach the purger as scripted.
+func newTestPurger(t *testing.T, s *discordtest.Server) *Purger {
+	t.Helper()
+	p, err := NewPurger(s.Client(discord.WithoutRateLimiter()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.SetSearchDelay(time.Millisecond)
+	p.SetDeleteDelay(time.Millisecond)
+	return p
+}
+
+// recorder collects updates from a purge, which may push from another goroutine.
+type recorder struct {
+	mu      sync.Mutex
+	updates []Update
+}
+
+func (r *recorder) push(u Update) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.updates = append(r.updates, u)
+}
+
+func (r *recorder) done(t *testing.T) UpdateDone {
+	t.Helper()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i := len(r.updates) - 1; i >= 0; i-- {
+		if d, ok := r.updates[i].(UpdateDone); ok {
+			return d
+		}
+	}
+	t.Fatal("no UpdateDone")
+	return UpdateDone{}
+}
+
+func ids(msgs []discord.Message) []string {
+	out := make([]string, len(msgs))
+	for i, m := range msgs {
+		out[i] = m.ID
+	}
+	return out
+}
+
+func sameIDs(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	set := make(map[string]bool, len(a))
+	for _, id := range a {
+		set[id] = true
+	}
+	for _, id := range b {
+		if !set[id] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestPurgePaginates(t *testing.T) {
+	t.Parallel()
+	s := newTestServer(t)
+	var mine []discord.Message
+	for range 3 {
+		mine = append(mine, s.GenerateMessages("10", me, 2, "mine")...)
+		s.GenerateMessages("10", other, 90, "theirs")
+	}
+	p := newTestPurger(t, s)
+
+	var r recorder
+	if err := p.Purge(context.Background(), "10", r.push); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := s.Deleted(); !sameIDs(got, ids(mine)) {
+		t.Errorf("deleted %v, want %v", got, ids(mine))
+	}
+	if n := len(s.Messages("10")); n != 270 {
+		t.Errorf("%d messages left, want the other user's 270", n)
+	}
+	// 276 messages take three pages, and an empty fourth ends the walk.
+	if n := s.Requests(discordtest.RouteMessages); n != 4 {
+		t.Errorf("%d message fetches, want 4", n)
+	}
+	if d := r.done(t); d.Deleted != 6 || d.Failed != 0 || d.Stopped {
+		t.Errorf("done = %+v", d)
+	}
+}
+
+func TestPurgeRetriesRateLimits(t *testing.T) {
+	t.Parallel()
+	s := newTestServer(t)
+	mine := s.GenerateMessages("10", me, 3, "mine")
+	s.ScriptRateLimit(discordtest.RouteMessages, 1, discordtest.RateLimit{RetryAfter: 10 * time.Millisecond})
+	s.ScriptRateLimit(discordtest.RouteDelete, 2, discordtest.RateLimit{RetryAfter: 10 * time.Millisecond})
+	p := newTestPurger(t, s)
+
+	var r recorder
+	if err := p.Purge(context.Background(), "10", r.push); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := s.Deleted(); !sameIDs(got, ids(mine)) {
+		t.Errorf("deleted %v, want %v", got, ids(mine))
+	}
+	d := r.done(t)
+	if d.Deleted != 3 || d.Throttled != 3 {
+		t.Errorf("done = %+v, want 3 deleted and 3 throttled", d)
+	}
+	limited := 0
+	for _, u := range r.updates {
+		if _, ok := u.(UpdateRateLimited); ok {
+			limited++
+		}
+	}
+	if limited != 3 {
+		t.Errorf("%d UpdateRateLimited, want 3", limited)
+	}
+}
+
+func TestPurgeGivesUpAfterConsecutiveRateLimits(t *testing.T) {
+	t.Parallel()
+	s := newTestServer(t)
+	s.GenerateMessages("10", me, 1, "mine")
+	s.ScriptRateLimit(discordtest.RouteDelete, 10, discordtest.RateLimit{RetryAfter: time.Millisecond})
+	p := newTestPurger(t, s)
+
+	var r recorder
+	if err := p.Purge(context.Background(), "10", r.push); err == nil {
+		t.Fatal("Purge succeeded through 10 consecutive 429s")
+	}
+	if n := len(s.Deleted()); n != 0 {
+		t.Errorf("%d messages deleted", n)
+	}
+}
+
+func TestPurgeCancelSavesCheckpoint(t *testing.T) {
+	t.Parallel()
+	s := newTestServer(t)
+	mine := s.GenerateMessages("10", me, 5, "mine")
+	p := newTestPurger(t, s)
+	p.SetCheckpointStore(NewCheckpointStore(t.TempDir()))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var r recorder
+	err := p.Purge(ctx, "10", func(u Update) {
+		r.push(u)
+		if _, ok := u.(UpdateDeleted); ok {
+			cancel()
+		}
+	})
+	if !IsStopped(err) {
+		t.Fatalf("Purge = %v, want it stopped", err)
+	}
+	if d := r.done(t); !d.Stopped || d.Deleted != 1 {
+		t.Errorf("done = %+v, want stopped after 1 delete", d)
+	}
+
+	cp, err := p.LoadCheckpoint("10")
+	if err != nil || cp == nil {
+		t.Fatalf("LoadCheckpoint = %v, %v", cp, err)
+	}
+	if cp.Deleted != 1 || !cp.DeletedIDs.Has(mine[4].ID) {
+		t.Errorf("checkpoint = %+v, want the newest message deleted", cp)
+	}
+
+	// Resuming finishes the rest and removes the checkpoint.
+	p.ResumeFrom(cp)
+	r = recorder{}
+	if err := p.Purge(context.Background(), "10", r.push); err != nil {
+		t.Fatal(err)
+	}
+	if d := r.done(t); d.Deleted != 5 {
+		t.Errorf("resumed done = %+v, want 5 deleted in total", d)
+	}
+	if got := s.Deleted(); !sameIDs(got, ids(mine)) {
+		t.Errorf("deleted %v, want %v", got, ids(mine))
+	}
+	if cp, _ := p.LoadCheckpoint("10"); cp != nil {
+		t.Errorf("checkpoint left after a finished purge: %+v", cp)
+	}
+}
+
+func TestPurgePause(t *testing.T) {
+	t.Parallel()
+	s := newTestServer(t)
+	mine := s.GenerateMessages("10", me, 2, "mine")
+	p := newTestPurger(t, s)
+
+	paused := make(chan struct{})
+	var r recorder
+	p.Pause()
+	errc := make(chan error, 1)
+	go func() {
+		errc <- p.Purge(context.Background(), "10", func(u Update) {
+			r.push(u)
+			if _, ok := u.(UpdatePaused); ok {
+				close(paused)
+			}
+		})
+	}()
+
+	select {
+	case <-paused:
+	case <-time.After(5 * time.Second):
+		t.Fatal("purge never paused")
+	}
+	if !p.Paused() {
+		t.Error("Paused() = false while paused")
+	}
+	if n := s.Requests(discordtest.RouteMessages) + s.Requests(discordtest.RouteDelete); n != 0 {
+		t.Errorf("%d requests sent while paused", n)
+	}
+
+	p.Resume()
+	if err := <-errc; err != nil {
+		t.Fatal(err)
+	}
+	if got := s.Deleted(); !sameIDs(got, ids(mine)) {
+		t.Errorf("deleted %v, want %v", got, ids(mine))
+	}
+	resumed := false
+	for _, u := range r.updates {
+		if _, ok := u.(UpdateResumed); ok {
+			resumed = true
+		}
+	}
+	if !resumed {
+		t.Error("no UpdateResumed")
+	}
+}
+
+func TestPurgeCancelWhilePaused(t *testing.T) {
+	t.Parallel()
+	s := newTestServer(t)
+	s.GenerateMessages("10", me, 1, "mine")
+	p := newTestPurger(t, s)
+	p.Pause()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var r recorder
+	err := p.Purge(ctx, "10", func(u Update) {
+		r.push(u)
+		if _, ok := u.(UpdatePaused); ok {
+			cancel()
+		}
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Purge = %v, want context.Canceled", err)
+	}
+	if d := r.done(t); !d.Stopped {
+		t.Errorf("done = %+v, want stopped", d)
+	}
+	if n := len(s.Deleted()); n != 0 {
+		t.Errorf("%d messages deleted while paused", n)
+	}
+}
+
+func TestClientWithOtherAPIVersion(t *testing.T) {
+	t.Parallel()
+	s := newTestServer(t)
+	for _, v := range []int{0, 9, 10} {
+		c := s.Client(discord.WithAPIVersion(v))
+		if err := c.FetchCurrentUser(); err != nil {
+			t.Errorf("API version %d: %v", v, err)
+		}
+	}
+}
+
+// newListedMessages adds 102 messages of the user to channel "10", as a data package
+// lists them: without an author. Only the two oldest and the 51st contain "del", so with
+// that filter one is deleted from the first page of 100 and two from the second.
+func newListedMessages(s *discordtest.Server) []discord.Message {
+	var msgs []discord.Message
+	msgs = append(msgs, s.GenerateMessages("10", me, 2, "del")...)
+	msgs = append(msgs, s.GenerateMessages("10", me, 48, "keep")...)
+	msgs = append(msgs, s.GenerateMessages("10", me, 1, "del")...)
+	msgs = append(msgs, s.GenerateMessages("10", me, 51, "keep")...)
+	for i := range msgs {
+		msgs[i].Author = discord.Author{}
+	}
+	return msgs
+}
+
+func TestPurgeMessages(t *testing.T) {
+	t.Parallel()
+	s := newTestServer(t)
+	msgs := newListedMessages(s)
+	s.GenerateMessages("10", me, 1, "del, but not listed")
+	p := newTestPurger(t, s)
+	p.SetFilters([]string{"del"})
+
+	var r recorder
+	if err := p.PurgeMessages(context.Background(), "10", msgs, r.push); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := s.Deleted(), []string{msgs[50].ID, msgs[1].ID, msgs[0].ID}; !sameIDs(got, want) {
+		t.Errorf("deleted %v, want %v", got, want)
+	}
+	if n := s.Requests(discordtest.RouteMessages); n != 0 {
+		t.Errorf("%d message fetches, want the channel left unread", n)
+	}
+	if d := r.done(t); d.Deleted != 3 || d.Failed != 0 {
+		t.Errorf("done = %+v", d)
+	}
+}
+
+func TestPurgeMessagesResumes(t *testing.T) {
+	t.Parallel()
+	s := newTestServer(t)
+	msgs := newListedMessages(s)
+	p := newTestPurger(t, s)
+	p.SetFilters([]string{"del"})
+	p.SetCheckpointStore(NewCheckpointStore(t.TempDir()))
+
+	// Stop on the first delete of the second page, once the cursor has moved past the first.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	err := p.PurgeMessages(ctx, "10", msgs, func(u Update) {
+		if d, ok := u.(UpdateDeleted); ok && d.ID == msgs[1].ID {
+			cancel()
+		}
+	})
+	if !IsStopped(err) {
+		t.Fatalf("PurgeMessages = %v, want it stopped", err)
+	}
+	cp, err := p.LoadCheckpoint("10")
+	if err != nil || cp == nil {
+		t.Fatalf("LoadCheckpoint = %v, %v", cp, err)
+	}
+	if cp.Before != msgs[2].ID {
+		t.Errorf("checkpoint before %s, want the end of the first page %s", cp.Before, msgs[2].ID)
+	}
+
+	p.ResumeFrom(cp)
+	var r recorder
+	if err := p.PurgeMessages(context.Background(), "10", msgs, r.push); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := s.Deleted(), []string{msgs[50].ID, msgs[1].ID, msgs[0].ID}; !sameIDs(got, want) {
+		t.Errorf("deleted %v, want %v", got, want)
+	}
+	// Nothing above the cursor is tried again.
+	if n := s.Requests(discordtest.RouteDelete); n != 3 {
+		t.Errorf("%d deletes, want 3", n)
+	}
+	if d := r.done(t); d.Deleted != 3 {
+		t.Errorf("resumed done = %+v, want 3 deleted in total", d)
+	}
+}
+
+func TestPurgeDoesNotResumeListCheckpoints(t *testing.T) {
+	t.Parallel()
+	s := newTestServer(t)
+	msgs := newListedMessages(s)
+	newer := s.GenerateMessages("10", me, 1, "del, but not listed")
+	p := newTestPurger(t, s)
+	p.SetFilters([]string{"del"})
+	p.SetCheckpointStore(NewCheckpointStore(t.TempDir()))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	p.PurgeMessages(ctx, "10", msgs, func(u Update) {
+		if d, ok := u.(UpdateDeleted); ok && d.ID == msgs[1].ID {
+			cancel()
+		}
+	})
+	cp, err := p.LoadCheckpoint("10")
+	if err != nil || cp == nil {
+		t.Fatalf("LoadCheckpoint = %v, %v", cp, err)
+	}
+	if p.CanResume(cp) {
+		t.Error("CanResume = true for a checkpoint of a list")
+	}
+
+	// Purge reads the whole channel, so the list's cursor would skip the newer message.
+	p.ResumeFrom(cp)
+	var r recorder
+	if err := p.Purge(context.Background(), "10", r.push); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{msgs[50].ID, msgs[1].ID, newer[0].ID, msgs[0].ID}
+	if got := s.Deleted(); !sameIDs(got, want) {
+		t.Errorf("deleted %v, want %v", got, want)
+	}
+}
+
+func TestDryRun(t *testing.T) {
+	t.Parallel()
+	s := newTestServer(t)
+	mine := s.GenerateMessages("10", me, 3, "mine")
+	s.GenerateMessages("10", other, 5, "theirs")
+	s.AddMessages("10", discord.Message{Author: me, Attachments: []discord.Attachment{{ID: "5", Filename: "a.png"}, {ID: "6", Filename: "b.png"}}})
+	withFile := s.Messages("10")[:1]
+	mine = append(mine, withFile...)
+	p := newTestPurger(t, s)
+	p.SetDryRun(true)
+
+	var r recorder
+	if err := p.Purge(context.Background(), "10", r.push); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(s.Deleted()); n != 0 {
+		t.Errorf("dry run deleted %d messages", n)
+	}
+	if n := s.Requests(discordtest.RouteDelete); n != 0 {
+		t.Errorf("dry run sent %d deletes", n)
+	}
+
+	var matched []string
+	for _, u := range r.updates {
+		if m, ok := u.(UpdateMatched); ok {
+			matched = append(matched, m.ID)
+		}
+	}
+	if !sameIDs(matched, ids(mine)) {
+		t.Errorf("matched %v, want %v", matched, ids(mine))
+	}
+	d := r.done(t)
+	if !d.DryRun || d.Matched != 4 || d.Attachments != 2 || d.Deleted != 0 {
+		t.Errorf("done = %+v, want 4 matched with 2 attachments", d)
+	}
+	if !d.Oldest.Equal(mine[0].Time()) || !d.Newest.Equal(withFile[0].Time()) {
+		t.Errorf("range %s to %s, want %s to %s", d.Oldest, d.Newest, mine[0].Time(), withFile[0].Time())
+	}
+}
+
+// recordingArchive remembers what it was given and whether the server still had each
+// message at that point. It fails for the message with ID failOn.
+type recordingArchive struct {
+	s      *discordtest.Server
+	failOn string
+
+	written     []string
+	alreadyGone []string
+	closed      bool
+}
+
+func (a *recordingArchive) Write(ctx context.Context, m discord.Message) error {
+	if m.ID == a.failOn {
+		return errors.New("disk full")
+	}
+	for _, id := range a.s.Deleted() {
+		if id == m.ID {
+			a.alreadyGone = append(a.alreadyGone, m.ID)
+		}
+	}
+	a.written = append(a.written, m.ID)
+	return nil
+}
+
+func (a *recordingArchive) Close() error {
+	a.closed = true
+	return nil
+}
+
+func TestArchiveBeforeDelete(t *testing.T) {
+	t.Parallel()
+	s := newTestServer(t)
+	mine := s.GenerateMessages("10", me, 3, "mine")
+	s.GenerateMessages("10", other, 2, "theirs")
+	p := newTestPurger(t, s)
+	a := &recordingArchive{s: s}
+	p.SetArchive(a)
+
+	var r recorder
+	if err := p.Purge(context.Background(), "10", r.push); err != nil {
+		t.Fatal(err)
+	}
+	if !sameIDs(a.written, ids(mine)) {
+		t.Errorf("archived %v, want %v", a.written, ids(mine))
+	}
+	if len(a.alreadyGone) != 0 {
+		t.Errorf("%v archived after they were deleted", a.alreadyGone)
+	}
+	if got := s.Deleted(); !sameIDs(got, ids(mine)) {
+		t.Errorf("deleted %v, want %v", got, ids(mine))
+	}
+	if a.closed {
+		t.Error("Purge closed the archive, which belongs to the caller")
+	}
+}
+
+func TestArchiveFailureKeepsMessage(t *testing.T) {
+	t.Parallel()
+	s := newTestServer(t)
+	mine := s.GenerateMessages("10", me, 3, "mine")
+	p := newTestPurger(t, s)
+	// Newest first, so the newest message goes through and the one after it fails.
+	p.SetArchive(&recordingArchive{s: s, failOn: mine[1].ID})
+
+	var r recorder
+	if err := p.Purge(context.Background(), "10", r.push); err == nil {
+		t.Fatal("Purge succeeded although a message couldn't be archived")
+	}
+	if got := s.Deleted(); len(got) != 1 || got[0] != mine[2].ID {
+		t.Errorf("deleted %v, want only %s", got, mine[2].ID)
+	}
+	if n := len(s.Messages("10")); n != 2 {
+		t.Errorf("%d messages left, want the unarchived one and the one after it", n)
+	}
+	var failed bool
+	for _, u := range r.updates {
+		if f, ok := u.(UpdateFailed); ok && f.MessageID == mine[1].ID {
+			failed = true
+		}
+	}
+	if !failed {
+		t.Error("no UpdateFailed for the message that couldn't be archived")
+	}
+}
@@ -1,10 +1,17 @@
 package purge
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"math/rand"
+	"net/http"
+	"purge/internal/archive"
 	"purge/internal/discord"
-	"strings"
+	"sort"
+	"sync"
 	"time"
 )
 
@@ -12,10 +19,29 @@ type Purger struct {
 	client *discord.Client
 	userID string
 
-	Filters     []string
+	archive  archive.Writer   // See SetArchive.
+	recorder archive.Recorder // See SetReport.
+
+	filter      Filter   // Set from the settings, see SetFilters and SetFilterExpr.
+	filterText  string   // What filter was made from, for settingsKey.
+	extra       []Filter // Added with AddFilter.
+	extraKeys   []string // The keys of extra, for settingsKey.
 	searchDelay time.Duration
 	deleteDelay time.Duration
 	maxAttempts int
+	dryRun      bool
+	search      bool // Find messages with Discord's search instead of reading the whole channel.
+
+	// Only messages sent in [after, before) are purged. Zero means unbounded.
+	after  time.Time
+	before time.Time
+
+	checkpoints *CheckpointStore
+	resumeFrom  *Checkpoint
+
+	mu     sync.Mutex
+	paused bool
+	resume chan struct{}
 }
 
 func NewPurger(client *discord.Client) (*Purger, error) {
@@ -35,8 +61,31 @@ func NewPurger(client *discord.Client) (*Purger, error) {
 	}, nil
 }
 
+// SetFilters selects messages containing any of filters, ignoring case.
 func (p *Purger) SetFilters(filters []string) {
-	p.Filters = filters
+	p.filter = Keywords(filters)
+	p.filterText = fmt.Sprintf("keywords:%q", filters)
+}
+
+// AddFilter adds a filter that messages must match as well, on top of SetFilters or
+// SetFilterExpr and any filters added before. key names f and its parameters, e.g.
+// "min-length=20": checkpoints are only resumed with the same keys, so it has to
+// change whenever f would select other messages.
+func (p *Purger) AddFilter(key string, f Filter) {
+	p.extra = append(p.extra, f)
+	p.extraKeys = append(p.extraKeys, key)
+}
+
+// SetFilterExpr selects messages matching a filter setting: keywords, or an expression
+// after "expr:", see ParseFilter.
+func (p *Purger) SetFilterExpr(expr string) error {
+	e, err := ParseFilter(expr)
+	if err != nil {
+		return err
+	}
+	p.filter = e
+	p.filterText = expr
+	return nil
 }
 
 func (p *Purger) SetSearchDelay(d time.Duration) {
@@ -51,81 +100,435 @@ func (p *Purger) SetDeleteDelay(d time.Duration) {
 	}
 }
 
-func (p *Purger) Purge(channelID string, push func(Update)) error {
-	var before string
-	var deleted, failed, throttled int
+func (p *Purger) SetMaxAttempts(n int) {
+	if n > 0 {
+		p.maxAttempts = n
+	}
+}
+
+// SetDateRange limits the purge to messages sent at or after after and before before.
+// Either may be zero. Pagination starts at before and stops once it passes after.
+func (p *Purger) SetDateRange(after, before time.Time) {
+	p.after = after
+	p.before = before
+}
+
+// SetDryRun makes Purge only report matching messages with UpdateMatched instead of deleting them.
+func (p *Purger) SetDryRun(dryRun bool) {
+	p.dryRun = dryRun
+}
+
+// SetSearch makes Purge find the user's messages with Discord's search instead of paging
+// through every message in the channel. This is much faster in busy channels, but only
+// finds what Discord has indexed.
+func (p *Purger) SetSearch(search bool) {
+	p.search = search
+}
+
+// SetArchive makes Purge write every message to w before deleting it. A message that
+// can't be archived is not deleted and the purge stops. The caller closes w.
+func (p *Purger) SetArchive(w archive.Writer) {
+	p.archive = w
+}
+
+// SetReport makes Purge record the outcome of every matched message to r. Like the
+// archive, a write error stops the purge. The caller closes r.
+func (p *Purger) SetReport(r archive.Recorder) {
+	p.recorder = r
+}
+
+// SetCheckpointStore makes Purge save its progress to store after every page and delete.
+func (p *Purger) SetCheckpointStore(store *CheckpointStore) {
+	p.checkpoints = store
+}
+
+// LoadCheckpoint returns the saved progress for channelID, or nil if there is none
+// or no checkpoint store is set.
+func (p *Purger) LoadCheckpoint(channelID string) (*Checkpoint, error) {
+	if p.checkpoints == nil {
+		return nil, nil
+	}
+	return p.checkpoints.Load(p.userID, channelID)
+}
+
+// ResumeFrom makes the next Purge of cp.ChannelID continue from cp rather than the newest message.
+// A checkpoint saved with other settings is discarded instead, see CanResume.
+func (p *Purger) ResumeFrom(cp *Checkpoint) {
+	p.resumeFrom = cp
+}
+
+// CanResume reports whether Purge would continue from cp: it has to have been saved by a
+// Purge of the same channel with the same filters, date range and search setting. Resuming
+// with different ones would skip whatever the old cursor had already passed.
+func (p *Purger) CanResume(cp *Checkpoint) bool {
+	return cp != nil && cp.UserID == p.userID && cp.Settings == p.settingsKey(sourceChannel)
+}
+
+// Sources of the messages a purge goes through, part of settingsKey.
+const (
+	sourceChannel = "channel"
+	sourceList    = "list" // PurgeMessages, followed by a hash of the message IDs.
+)
+
+// settingsKey sums up everything that decides which messages a purge selects, and where
+// it gets them from. Filters from AddFilter count by their keys.
+func (p *Purger) settingsKey(source string) string {
+	bound := func(t time.Time) string {
+		if t.IsZero() {
+			return ""
+		}
+		return t.UTC().Format(time.RFC3339Nano)
+	}
+	h := sha256.New()
+	fmt.Fprintf(h, "filter=%s\nextra=%q\nafter=%s\nbefore=%s\nsearch=%t\nsource=%s",
+		p.filterText, p.extraKeys, bound(p.after), bound(p.before), p.search, source)
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+// Purge deletes the user's messages in channelID, newest first. channelID can be a DM,
+// a group DM, or a guild text channel or thread. Cancelling ctx stops it
+// between requests; the final UpdateDone then carries the partial counts and ctx.Err() is returned.
+func (p *Purger) Purge(ctx context.Context, channelID string, push func(Update)) error {
+	return p.purge(ctx, channelID, sourceChannel, p.pages, push)
+}
+
+// PurgeMessages deletes msgs from channelID without reading the channel, e.g. messages
+// listed in a data package. They are taken to be the user's own, so their Author is
+// filled in; the date range and filters still apply. It reports and resumes like Purge.
+func (p *Purger) PurgeMessages(ctx context.Context, channelID string, msgs []discord.Message, push func(Update)) error {
+	own := make([]discord.Message, len(msgs))
+	for i, m := range msgs {
+		if m.Author.ID == "" {
+			m.Author = discord.Author{ID: p.userID, Username: p.client.UserInfo.Username}
+		}
+		own[i] = m
+	}
+	sort.Slice(own, func(i, j int) bool { return discord.SnowflakeLess(own[j].ID, own[i].ID) })
+
+	h := sha256.New()
+	for _, m := range own {
+		fmt.Fprintln(h, m.ID)
+	}
+	source := sourceList + ":" + hex.EncodeToString(h.Sum(nil))[:16]
+
+	return p.purge(ctx, channelID, source, func(ctx context.Context, channelID, before string, throttled *int, push func(Update), fn func([]discord.Message) error) error {
+		return p.listPages(ctx, own, before, push, fn)
+	}, push)
+}
+
+// pageSource hands fn the messages of channelID a page at a time, newest first, starting
+// below before. Rate limited requests are counted in throttled.
+type pageSource func(ctx context.Context, channelID, before string, throttled *int, push func(Update), fn func([]discord.Message) error) error
+
+func (p *Purger) purge(ctx context.Context, channelID, source string, pages pageSource, push func(Update)) error {
 	const max429 = 10 // Safeguard, if you get 10 consecutive 429, discord has probably detected you using some tool.
 
+	push = withChannel(channelID, push)
+
+	if p.dryRun {
+		return p.dryRunPurge(ctx, channelID, pages, push)
+	}
+
+	key := p.settingsKey(source)
+	cp := newCheckpoint(p.userID, channelID, key)
+	if r := p.resumeFrom; r != nil && r.ChannelID == channelID && r.UserID == p.userID {
+		if r.Settings == key {
+			cp = r
+			push(UpdateInfo{Message: fmt.Sprintf("Resuming from checkpoint (%d deleted so far)", cp.Deleted)})
+		} else {
+			// Saving the new checkpoint replaces the old one.
+			push(UpdateInfo{Message: "Checkpoint was saved with other settings, starting over"})
+		}
+	}
+
+	stopped := func() error {
+		p.saveCheckpoint(cp, push)
+		push(UpdateDone{Deleted: cp.Deleted, Failed: cp.Failed, Throttled: cp.Throttled, Stopped: true})
+		return ctx.Err()
+	}
+
+	err := pages(ctx, channelID, cp.Before, &cp.Throttled, push, func(msgs []discord.Message) error {
+		for _, m := range msgs {
+			if cp.Seen(m.ID) || !p.matches(m) {
+				continue
+			}
+
+			if err := p.waitIfPaused(ctx, push); err != nil {
+				return err
+			}
+
+			if p.archive != nil {
+				if err := p.archive.Write(ctx, m); err != nil {
+					err = fmt.Errorf("archiving message %s: %w", m.ID, err)
+					push(UpdateFailed{MessageID: m.ID, Message: err.Error()})
+					return err
+				}
+			}
+
+			err := p.deleteMessage(ctx, channelID, m, push, cp, max429)
+			p.saveCheckpoint(cp, push)
+
+			// Interrupted deletes are in neither set and get reported by the run that finishes them.
+			switch {
+			case cp.DeletedIDs.Has(m.ID):
+				err = errors.Join(err, p.report(m, archive.OutcomeDeleted, push))
+			case cp.FailedIDs.Has(m.ID):
+				err = errors.Join(err, p.report(m, archive.OutcomeFailed, push))
+			}
+			if err != nil {
+				return err
+			}
+		}
+		cp.Before = msgs[len(msgs)-1].ID
+		cp.forgetPassed()
+		p.saveCheckpoint(cp, push)
+		return nil
+	})
+	if ctx.Err() != nil {
+		return stopped()
+	}
+	if err != nil {
+		p.saveCheckpoint(cp, push)
+		return err
+	}
+
+	if p.checkpoints != nil {
+		if err := p.checkpoints.Remove(p.userID, channelID); err != nil {
+			push(UpdateInfo{Message: "could not remove checkpoint: " + err.Error()})
+		}
+	}
+	p.resumeFrom = nil
+
+	push(UpdateDone{Deleted: cp.Deleted, Failed: cp.Failed, Throttled: cp.Throttled})
+
+	return nil
+}
+
+// dryRunPurge walks the channel like Purge but never deletes or touches checkpoints.
+func (p *Purger) dryRunPurge(ctx context.Context, channelID string, pages pageSource, push func(Update)) error {
+	done := UpdateDone{DryRun: true}
+
+	err := pages(ctx, channelID, "", &done.Throttled, push, func(msgs []discord.Message) error {
+		for _, m := range msgs {
+			if !p.matches(m) {
+				continue
+			}
+
+			t := m.Time()
+			done.Matched++
+			done.Attachments += len(m.Attachments)
+			if !t.IsZero() {
+				if done.Oldest.IsZero() || t.Before(done.Oldest) {
+					done.Oldest = t
+				}
+				if t.After(done.Newest) {
+					done.Newest = t
+				}
+			}
+			push(UpdateMatched{ID: m.ID, Content: m.Content, Timestamp: t, Attachments: len(m.Attachments)})
+			if err := p.report(m, archive.OutcomeSkipped, push); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if ctx.Err() != nil {
+		done.Stopped = true
+		push(done)
+		return ctx.Err()
+	}
+	if err != nil {
+		return err
+	}
+
+	push(done)
+	return nil
+}
+
+// pages is the pageSource of Purge, either walk or searchWalk.
+func (p *Purger) pages(ctx context.Context, channelID, before string, throttled *int, push func(Update), fn func([]discord.Message) error) error {
+	if p.search {
+		return p.searchWalk(ctx, channelID, before, throttled, push, fn)
+	}
+	return p.walk(ctx, channelID, before, throttled, push, fn)
+}
+
+// listPages hands out msgs, which are sorted newest first, in pages of 100 like walk.
+func (p *Purger) listPages(ctx context.Context, msgs []discord.Message, before string, push func(Update), fn func([]discord.Message) error) error {
+	if before != "" {
+		i := sort.Search(len(msgs), func(i int) bool { return discord.SnowflakeLess(msgs[i].ID, before) })
+		msgs = msgs[i:]
+	}
+	for len(msgs) > 0 {
+		if err := p.waitIfPaused(ctx, push); err != nil {
+			return err
+		}
+		n := min(100, len(msgs))
+		if err := fn(msgs[:n]); err != nil {
+			return err
+		}
+		msgs = msgs[n:]
+	}
+	return nil
+}
+
+// walk pages backwards through channelID starting before the given ID, handing every
+// page to fn. Rate limits are waited out here; fetch errors are pushed as UpdateFailed.
+func (p *Purger) walk(ctx context.Context, channelID, before string, throttled *int, push func(Update), fn func([]discord.Message) error) error {
+	// Skip everything newer than the range without fetching it.
+	if before == "" && !p.before.IsZero() {
+		before = discord.SnowflakeFromTime(p.before)
+	}
+
 	for {
-		msgs, rl, err := p.client.FetchMessages(channelID, before)
+		if err := p.waitIfPaused(ctx, push); err != nil {
+			return err
+		}
+
+		msgs, rl, err := p.client.FetchMessages(ctx, channelID, before)
 
 		if rl.Hit {
-			throttled++
-			if err := p.handleRateLimit(rl.RetryAfter); err != nil {
-				push(UpdateFailed{Message: err.Error()})
+			*throttled++
+			push(UpdateRateLimited{Timeout: rl.RetryAfter})
+			if err := p.handleRateLimit(ctx, rl.RetryAfter); err != nil {
 				return err
 			}
-			push(UpdateRateLimited{Timeout: rl.RetryAfter})
 			continue
 		}
 
 		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
 			push(UpdateFailed{Message: err.Error()})
 			return err
 		}
 
 		if len(msgs) == 0 {
-			break
+			return nil
 		}
 
-		for _, m := range msgs {
-			if m.Author.ID != p.userID || !p.matchesFilters(m.Content) {
-				continue
-			}
-
-			if err := p.deleteMessage(channelID, m, push, &deleted, &failed, &throttled, max429); err != nil {
-				return err
-			}
+		if err := fn(msgs); err != nil {
+			return err
 		}
 		before = msgs[len(msgs)-1].ID
-		time.Sleep(p.searchDelay + RandDuration(50*time.Millisecond, 200*time.Millisecond))
+
+		// Pages are newest first, so nothing older can be in range either.
+		if !p.after.IsZero() && msgs[len(msgs)-1].Time().Before(p.after) {
+			return nil
+		}
+		if err := discord.Sleep(ctx, p.searchDelay+RandDuration(50*time.Millisecond, 200*time.Millisecond)); err != nil {
+			return err
+		}
+	}
+}
+
+func (p *Purger) report(m discord.Message, outcome archive.Outcome, push func(Update)) error {
+	if p.recorder == nil {
+		return nil
+	}
+	if err := p.recorder.Record(m, outcome); err != nil {
+		err = fmt.Errorf("recording message %s: %w", m.ID, err)
+		push(UpdateFailed{MessageID: m.ID, Message: err.Error()})
+		return err
+	}
+	return nil
+}
+
+// A failed save shouldn't abort the purge itself, so it is only reported.
+func (p *Purger) saveCheckpoint(cp *Checkpoint, push func(Update)) {
+	if p.checkpoints == nil {
+		return
+	}
+	if err := p.checkpoints.Save(cp); err != nil {
+		push(UpdateInfo{Message: "could not save checkpoint: " + err.Error()})
+	}
+}
+
+// Pause makes a running Purge halt before its next request. Safe to call from any goroutine.
+func (p *Purger) Pause() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.paused {
+		p.paused = true
+		p.resume = make(chan struct{})
+	}
+}
+
+func (p *Purger) Resume() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.paused {
+		p.paused = false
+		close(p.resume)
+	}
+}
+
+func (p *Purger) Paused() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.paused
+}
+
+// waitIfPaused is called between requests. It blocks while paused and returns ctx.Err() if cancelled.
+func (p *Purger) waitIfPaused(ctx context.Context, push func(Update)) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
 	}
 
-	push(UpdateDone{Deleted: deleted, Failed: failed, Throttled: throttled})
+	p.mu.Lock()
+	if !p.paused {
+		p.mu.Unlock()
+		return nil
+	}
+	resume := p.resume
+	p.mu.Unlock()
 
+	push(UpdatePaused{})
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-resume:
+	}
+	push(UpdateResumed{})
 	return nil
 }
 
-func (p *Purger) matchesFilters(content string) bool {
-	if len(p.Filters) == 0 {
-		return true
+// matches reports whether m is one of the user's messages that this purge selects.
+func (p *Purger) matches(m discord.Message) bool {
+	if m.Author.ID != p.userID || !m.Deletable() {
+		return false
+	}
+	if t := m.Time(); (!p.after.IsZero() && t.Before(p.after)) || (!p.before.IsZero() && !t.Before(p.before)) {
+		return false
 	}
-	lc := strings.ToLower(content)
-	for _, f := range p.Filters {
-		if strings.Contains(lc, strings.ToLower(f)) {
-			return true
+	if p.filter != nil && !p.filter.Match(m) {
+		return false
+	}
+	for _, f := range p.extra {
+		if !f.Match(m) {
+			return false
 		}
 	}
-	return false
+	return true
 }
 
-func (p *Purger) handleRateLimit(retryAfter time.Duration) error {
+func (p *Purger) handleRateLimit(ctx context.Context, retryAfter time.Duration) error {
 	if retryAfter > p.searchDelay {
 		p.searchDelay = retryAfter
 	}
-	time.Sleep(retryAfter + RandDuration(100*time.Millisecond, 400*time.Millisecond))
-	return nil
+	return discord.Sleep(ctx, retryAfter+RandDuration(100*time.Millisecond, 400*time.Millisecond))
 }
 
-func (p *Purger) deleteMessage(channelID string, m discord.Message, push func(Update), deleted, failed, throttled *int, max429 int) error {
+func (p *Purger) deleteMessage(ctx context.Context, channelID string, m discord.Message, push func(Update), cp *Checkpoint, max429 int) error {
 	attempts := 0
 	consec429 := 0
 
 	for attempts < p.maxAttempts {
-		rl, err := p.client.DeleteMessage(channelID, m)
+		rl, err := p.client.DeleteMessage(ctx, channelID, m)
 
 		if rl.Hit {
-			*throttled++
+			cp.Throttled++
 			consec429++
 			push(UpdateRateLimited{Timeout: rl.RetryAfter})
 
@@ -134,44 +537,48 @@ func (p *Purger) deleteMessage(channelID string, m discord.Message, push func(Up
 			}
 
 			if consec429 >= max429 {
-				push(UpdateFailed{Message: "too many 429s, exiting purge"})
+				push(UpdateFailed{MessageID: m.ID, Message: "too many 429s, exiting purge"})
 				return fmt.Errorf("too many consecutive 429s")
 			}
-			time.Sleep(rl.RetryAfter + RandDuration(100*time.Millisecond, 400*time.Millisecond))
+			if err := discord.Sleep(ctx, rl.RetryAfter+RandDuration(100*time.Millisecond, 400*time.Millisecond)); err != nil {
+				return err
+			}
 			continue
 		}
 
 		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
 			if isNotFound(err) {
-				*deleted++
-				push(UpdateDeleted{Content: m.Content})
-				time.Sleep(p.deleteDelay + RandDuration(50*time.Millisecond, 300*time.Millisecond))
-				return nil
+				cp.Deleted++
+				cp.DeletedIDs.Add(m.ID)
+				push(UpdateDeleted{ID: m.ID, Content: m.Content})
+				return discord.Sleep(ctx, p.deleteDelay+RandDuration(50*time.Millisecond, 300*time.Millisecond))
 			}
 			attempts++
 			if attempts >= p.maxAttempts {
-				*failed++
-				push(UpdateFailed{Message: err.Error()})
+				cp.Failed++
+				cp.FailedIDs.Add(m.ID)
+				push(UpdateFailed{MessageID: m.ID, Message: err.Error()})
 				return nil
 			}
-			time.Sleep(p.deleteDelay + RandDuration(50*time.Millisecond, 300*time.Millisecond))
+			if err := discord.Sleep(ctx, p.deleteDelay+RandDuration(50*time.Millisecond, 300*time.Millisecond)); err != nil {
+				return err
+			}
 			continue
 		}
-		*deleted++
-		push(UpdateDeleted{Content: m.Content})
-		time.Sleep(p.deleteDelay + RandDuration(50*time.Millisecond, 200*time.Millisecond))
-		return nil
+		cp.Deleted++
+		cp.DeletedIDs.Add(m.ID)
+		push(UpdateDeleted{ID: m.ID, Content: m.Content})
+		return discord.Sleep(ctx, p.deleteDelay+RandDuration(50*time.Millisecond, 200*time.Millisecond))
 	}
 	return nil
 }
 
 // If something is already deleted, this acts as a safeguard. Not found/404 means it's alteady been deleted.
 func isNotFound(err error) bool {
-	if err == nil {
-		return false
-	}
-	s := strings.ToLower(err.Error())
-	return strings.Contains(s, "404") || strings.Contains(s, "not found")
+	return hasStatus(err, http.StatusNotFound)
 }
 
 // To make the purge seem less robotic, adding random ms delays.
@@ -181,3 +588,8 @@ func RandDuration(min, max time.Duration) time.Duration {
 	}
 	return min + time.Duration(rand.Int63n(int64(max-min)))
 }
+
+// IsStopped reports whether err means the purge was cancelled rather than failed.
+func IsStopped(err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
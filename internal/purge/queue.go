@@ -0,0 +1,71 @@
+package purge
+
+import (
+	"context"
+	"fmt"
+)
+
+// Job is one channel of a queue, see PurgeQueue.
+type Job struct {
+	ChannelID string
+	Name      string // Only for progress updates, can be empty.
+}
+
+// PurgeQueue purges jobs one after another with the same settings. Each channel starts
+// with UpdateChannel and ends with UpdateChannelDone; the final UpdateDone adds them all
+// up. Channels the user can't read are skipped, and saved checkpoints are resumed automatically.
+func (p *Purger) PurgeQueue(ctx context.Context, jobs []Job, push func(Update)) error {
+	return p.runQueue(ctx, jobs, UpdateDone{DryRun: p.dryRun}, push)
+}
+
+func (p *Purger) runQueue(ctx context.Context, jobs []Job, total UpdateDone, push func(Update)) error {
+	for i, job := range jobs {
+		push(UpdateChannel{ChannelID: job.ChannelID, Name: job.Name, Index: i + 1, Total: len(jobs)})
+
+		p.ResumeFrom(nil)
+		if cp, err := p.LoadCheckpoint(job.ChannelID); err != nil {
+			push(UpdateInfo{ChannelID: job.ChannelID, Message: "ignoring checkpoint: " + err.Error()})
+		} else if cp != nil && !p.dryRun {
+			p.ResumeFrom(cp)
+		}
+
+		// A failed fetch is held back until it is clear whether the channel is just unreadable.
+		var fetchFailed *UpdateFailed
+		err := p.Purge(ctx, job.ChannelID, func(u Update) {
+			switch u := u.(type) {
+			case UpdateFailed:
+				if u.MessageID == "" {
+					fetchFailed = &u
+					return
+				}
+			case UpdateDone:
+				total.add(u)
+				push(UpdateChannelDone(u))
+				return
+			}
+			push(u)
+		})
+
+		switch {
+		case ctx.Err() != nil:
+			total.Stopped = true
+			push(total)
+			return ctx.Err()
+		case isForbidden(err):
+			// Purge leaves a checkpoint behind on errors, which is no use here.
+			if p.checkpoints != nil {
+				p.checkpoints.Remove(p.userID, job.ChannelID)
+			}
+			push(UpdateInfo{ChannelID: job.ChannelID, Message: "skipped, no access to its messages"})
+			continue
+		case err != nil:
+			if fetchFailed != nil {
+				push(*fetchFailed)
+			}
+			return fmt.Errorf("channel %s: %w", job.ChannelID, err)
+		}
+	}
+
+	push(total)
+	return nil
+}
@@ -0,0 +1,85 @@
+package purge
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"purge/internal/discord"
+)
+
+// failingArchive fails every write with an error whose text looks like a 403.
+type failingArchive struct{}
+
+func (failingArchive) Write(ctx context.Context, m discord.Message) error {
+	return errors.New("write /archive/403.jsonl: 403 files open, Missing Access")
+}
+
+func (failingArchive) Close() error { return nil }
+
+func TestQueueSkipsForbiddenChannels(t *testing.T) {
+	t.Parallel()
+	s := newTestServer(t)
+	s.AddChannel(discord.Channel{ID: "11", Type: discord.ChannelTypeDM})
+	s.AddChannel(discord.Channel{ID: "12", Type: discord.ChannelTypeDM})
+	first := s.GenerateMessages("10", me, 2, "mine")
+	s.GenerateMessages("11", me, 2, "mine")
+	last := s.GenerateMessages("12", me, 2, "mine")
+	s.Deny("11")
+	p := newTestPurger(t, s)
+
+	var r recorder
+	jobs := []Job{{ChannelID: "10"}, {ChannelID: "11"}, {ChannelID: "12"}}
+	if err := p.PurgeQueue(context.Background(), jobs, r.push); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := s.Deleted(), append(ids(first), ids(last)...); !sameIDs(got, want) {
+		t.Errorf("deleted %v, want %v", got, want)
+	}
+
+	var got []string
+	for _, u := range r.updates {
+		switch u := u.(type) {
+		case UpdateChannel:
+			got = append(got, fmt.Sprintf("channel %s %d/%d", u.ChannelID, u.Index, u.Total))
+		case UpdateChannelDone:
+			got = append(got, fmt.Sprintf("channel_done %s %d", u.ChannelID, u.Deleted))
+		case UpdateDone:
+			got = append(got, fmt.Sprintf("done %d channels %d deleted", u.Channels, u.Deleted))
+		}
+	}
+	// The unreadable channel is skipped without an UpdateChannelDone.
+	want := []string{
+		"channel 10 1/3", "channel_done 10 2",
+		"channel 11 2/3",
+		"channel 12 3/3", "channel_done 12 2",
+		"done 2 channels 4 deleted",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("updates:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestQueueStopsOnErrorsMentioning403(t *testing.T) {
+	t.Parallel()
+	s := newTestServer(t)
+	s.AddChannel(discord.Channel{ID: "11", Type: discord.ChannelTypeDM})
+	s.GenerateMessages("10", me, 1, "mine")
+	s.GenerateMessages("11", me, 1, "mine")
+	p := newTestPurger(t, s)
+	p.SetArchive(failingArchive{})
+
+	var r recorder
+	err := p.PurgeQueue(context.Background(), []Job{{ChannelID: "10"}, {ChannelID: "11"}}, r.push)
+	if err == nil {
+		t.Fatal("PurgeQueue skipped a channel whose archive failed")
+	}
+	if n := len(s.Deleted()); n != 0 {
+		t.Errorf("%d messages deleted without being archived", n)
+	}
+	if n := len(s.Messages("11")); n != 1 {
+		t.Errorf("queue went on to the next channel after an archive error")
+	}
+}
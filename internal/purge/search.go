@@ -0,0 +1,89 @@
+package purge
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"purge/internal/discord"
+)
+
+// maxIndexWaits is how often searchWalk waits for Discord to finish indexing before giving up.
+const maxIndexWaits = 10
+
+// searchWalk is walk using Discord's search, so only the user's own messages in the date
+// range are fetched. It pages with max_id rather than offset, since every delete would
+// shift the offsets of the results after it.
+func (p *Purger) searchWalk(ctx context.Context, channelID, before string, throttled *int, push func(Update), fn func([]discord.Message) error) error {
+	q := discord.SearchQuery{ChannelID: channelID, AuthorID: p.userID, MaxID: before}
+	if q.MaxID == "" && !p.before.IsZero() {
+		q.MaxID = discord.SnowflakeFromTime(p.before)
+	}
+	if !p.after.IsZero() {
+		q.MinID = discord.SnowflakeFromTime(p.after.Add(-time.Millisecond))
+	}
+
+	// Guild channels can only be searched through their guild.
+	ch, err := p.client.FetchChannel(ctx, channelID)
+	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		push(UpdateFailed{Message: err.Error()})
+		return err
+	}
+	q.GuildID = ch.GuildID
+
+	notReady := 0
+	for {
+		if err := p.waitIfPaused(ctx, push); err != nil {
+			return err
+		}
+
+		res, rl, err := p.client.SearchMessages(ctx, q)
+
+		if rl.Hit {
+			*throttled++
+			push(UpdateRateLimited{Timeout: rl.RetryAfter})
+			if err := p.handleRateLimit(ctx, rl.RetryAfter); err != nil {
+				return err
+			}
+			continue
+		}
+
+		var nr discord.IndexNotReadyError
+		if errors.As(err, &nr) && notReady < maxIndexWaits {
+			notReady++
+			push(UpdateInfo{Message: fmt.Sprintf("Discord is still indexing this channel, retrying in %s", nr.RetryAfter)})
+			if err := discord.Sleep(ctx, nr.RetryAfter); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			push(UpdateFailed{Message: err.Error()})
+			return err
+		}
+
+		msgs := res.Messages
+		if len(msgs) == 0 {
+			return nil
+		}
+		sort.Slice(msgs, func(i, j int) bool { return discord.SnowflakeLess(msgs[j].ID, msgs[i].ID) })
+
+		if err := fn(msgs); err != nil {
+			return err
+		}
+		q.MaxID = msgs[len(msgs)-1].ID
+
+		if err := discord.Sleep(ctx, p.searchDelay+RandDuration(50*time.Millisecond, 200*time.Millisecond)); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package purge
+
+import (
+	"context"
+	"testing"
+
+	"purge/internal/discord"
+	"purge/internal/discord/discordtest"
+)
+
+func newSearchPurger(t *testing.T, s *discordtest.Server) *Purger {
+	t.Helper()
+	p := newTestPurger(t, s)
+	p.SetSearch(true)
+	return p
+}
+
+func TestSearchPagesWithMaxID(t *testing.T) {
+	t.Parallel()
+	s := newTestServer(t)
+	var mine []discord.Message
+	for range 20 {
+		mine = append(mine, s.GenerateMessages("10", me, 2, "mine")...)
+		s.GenerateMessages("10", other, 1, "theirs")
+	}
+	p := newSearchPurger(t, s)
+
+	var r recorder
+	if err := p.Purge(context.Background(), "10", r.push); err != nil {
+		t.Fatal(err)
+	}
+	if got := s.Deleted(); !sameIDs(got, ids(mine)) {
+		t.Errorf("deleted %v, want %v", got, ids(mine))
+	}
+	if n := len(s.Messages("10")); n != 20 {
+		t.Errorf("%d messages left, want the other user's 20", n)
+	}
+	// 40 hits take two pages of 25, and an empty third ends the walk.
+	if n := s.Requests(discordtest.RouteSearch); n != 3 {
+		t.Errorf("%d searches, want 3", n)
+	}
+	if n := s.Requests(discordtest.RouteMessages); n != 0 {
+		t.Errorf("%d message fetches, want none", n)
+	}
+	if d := r.done(t); d.Deleted != 40 || d.Failed != 0 {
+		t.Errorf("done = %+v", d)
+	}
+}
+
+func TestSearchDateRange(t *testing.T) {
+	t.Parallel()
+	s := newTestServer(t)
+	msgs := s.GenerateMessages("10", me, 10, "mine")
+	p := newSearchPurger(t, s)
+	// A message exactly at after is in range, one exactly at before is not.
+	p.SetDateRange(msgs[3].Time(), msgs[7].Time())
+
+	var r recorder
+	if err := p.Purge(context.Background(), "10", r.push); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := s.Deleted(), ids(msgs[3:7]); !sameIDs(got, want) {
+		t.Errorf("deleted %v, want %v", got, want)
+	}
+}
+
+func TestSearchWaitsForIndexing(t *testing.T) {
+	t.Parallel()
+	s := newTestServer(t)
+	mine := s.GenerateMessages("10", me, 3, "mine")
+	s.ScriptIndexing(2)
+	p := newSearchPurger(t, s)
+
+	var r recorder
+	if err := p.Purge(context.Background(), "10", r.push); err != nil {
+		t.Fatal(err)
+	}
+	if got := s.Deleted(); !sameIDs(got, ids(mine)) {
+		t.Errorf("deleted %v, want %v", got, ids(mine))
+	}
+	// Two 202s, a page and the empty page after it.
+	if n := s.Requests(discordtest.RouteSearch); n != 4 {
+		t.Errorf("%d searches, want 4", n)
+	}
+}
+
+func TestSearchGivesUpOnIndexing(t *testing.T) {
+	t.Parallel()
+	s := newTestServer(t)
+	s.GenerateMessages("10", me, 3, "mine")
+	s.ScriptIndexing(maxIndexWaits + 5)
+	p := newSearchPurger(t, s)
+
+	var r recorder
+	if err := p.Purge(context.Background(), "10", r.push); err == nil {
+		t.Fatal("Purge succeeded while the index was never ready")
+	}
+	if n := s.Requests(discordtest.RouteSearch); n != maxIndexWaits+1 {
+		t.Errorf("%d searches, want %d", n, maxIndexWaits+1)
+	}
+	if n := len(s.Deleted()); n != 0 {
+		t.Errorf("%d messages deleted", n)
+	}
+}
+
+func TestSearchGuildChannel(t *testing.T) {
+	t.Parallel()
+	s := newTestServer(t)
+	newTestGuild(s)
+	mine := s.GenerateMessages("20", me, 3, "mine")
+	s.GenerateMessages("20", other, 3, "theirs")
+	s.GenerateMessages("30", me, 3, "in a thread")
+	p := newSearchPurger(t, s)
+
+	var r recorder
+	if err := p.Purge(context.Background(), "20", r.push); err != nil {
+		t.Fatal(err)
+	}
+	if got := s.Deleted(); !sameIDs(got, ids(mine)) {
+		t.Errorf("deleted %v, want only channel 20's %v", got, ids(mine))
+	}
+	if n := len(s.Messages("30")); n != 3 {
+		t.Errorf("%d messages left in another channel of the server, want 3", n)
+	}
+}
@@ -6,24 +6,106 @@ import "time"
 
 type Update any
 
+// Every update carries the ID of the channel being purged, so consumers can tell
+// channels apart when several are purged in a row.
+
 type UpdateDeleted struct {
-	Content string
+	ChannelID string
+	ID        string
+	Content   string
 }
 
 type UpdateFailed struct {
-	Message string
+	ChannelID string
+	MessageID string // Empty if the failure isn't about one message, e.g. fetching failed.
+	Message   string
 }
 
 type UpdateRateLimited struct {
-	Timeout time.Duration
+	ChannelID string
+	Timeout   time.Duration
 }
 
 type UpdateInfo struct {
-	Message string
+	ChannelID string
+	Message   string
+}
+
+// UpdateMatched is sent in dry-run mode for every message that would have been deleted.
+type UpdateMatched struct {
+	ChannelID   string
+	ID          string
+	Content     string
+	Timestamp   time.Time
+	Attachments int
+}
+
+type UpdatePaused struct {
+	ChannelID string
+}
+
+type UpdateResumed struct {
+	ChannelID string
 }
 
+// UpdateChannel is sent by PurgeGuild and PurgeQueue before they start on each channel or thread.
+type UpdateChannel struct {
+	ChannelID string
+	Name      string
+	Index     int // 1-based.
+	Total     int
+}
+
+// UpdateChannelDone is the UpdateDone of one channel during PurgeGuild or PurgeQueue.
+// The UpdateDone that ends them adds them all up.
+type UpdateChannelDone UpdateDone
+
 type UpdateDone struct {
+	ChannelID string
+	GuildID   string // Set instead of ChannelID at the end of PurgeGuild.
+	Channels  int    // Channels and threads PurgeGuild or PurgeQueue went through.
 	Deleted   int
 	Failed    int
 	Throttled int
+	Stopped   bool // Cancelled before reaching the end of the channel.
+
+	// Dry-run summary. Oldest and Newest are zero if nothing matched.
+	DryRun      bool
+	Matched     int
+	Attachments int
+	Oldest      time.Time
+	Newest      time.Time
+}
+
+// withChannel fills in ChannelID on every update before passing it on.
+func withChannel(channelID string, push func(Update)) func(Update) {
+	return func(u Update) {
+		switch v := u.(type) {
+		case UpdateDeleted:
+			v.ChannelID = channelID
+			u = v
+		case UpdateFailed:
+			v.ChannelID = channelID
+			u = v
+		case UpdateRateLimited:
+			v.ChannelID = channelID
+			u = v
+		case UpdateInfo:
+			v.ChannelID = channelID
+			u = v
+		case UpdateMatched:
+			v.ChannelID = channelID
+			u = v
+		case UpdatePaused:
+			v.ChannelID = channelID
+			u = v
+		case UpdateResumed:
+			v.ChannelID = channelID
+			u = v
+		case UpdateDone:
+			v.ChannelID = channelID
+			u = v
+		}
+		push(u)
+	}
 }
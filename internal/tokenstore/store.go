@@ -0,0 +1,263 @@
+// Package tokenstore keeps Discord tokens on disk encrypted with keys derived from passphrases.
+// The raw tokens are never written out, only the AES-GCM ciphertext.
+package tokenstore
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+const (
+	// Version 1 held a single token, version 2 a list of accounts.
+	version = 2
+	kdf     = "pbkdf2-sha256"
+
+	// Iterations is the PBKDF2 work factor for newly saved tokens, as recommended by OWASP for SHA-256.
+	Iterations = 600_000
+	saltSize   = 16
+	keySize    = 32
+)
+
+var (
+	ErrNoToken         = errors.New("no saved token")
+	ErrWrongPassphrase = errors.New("wrong passphrase")
+	ErrEmptyPassphrase = errors.New("passphrase must not be empty")
+)
+
+// Account labels a saved token. It is stored in the clear so the picker can list
+// accounts before anything is unlocked.
+type Account struct {
+	UserID   string `json:"user_id"` // Empty for a token saved by version 1.
+	Username string `json:"username"`
+}
+
+// Label is the name shown in the account picker.
+func (a Account) Label() string {
+	if a.Username == "" {
+		return "(saved token)"
+	}
+	return a.Username
+}
+
+// sealed is an encrypted token with the parameters needed to derive its key.
+// Byte fields are base64 in the JSON.
+type sealed struct {
+	KDF        string `json:"kdf,omitempty"`
+	Iterations int    `json:"iterations,omitempty"`
+	Salt       []byte `json:"salt,omitempty"`
+	Nonce      []byte `json:"nonce,omitempty"`
+	Ciphertext []byte `json:"ciphertext,omitempty"`
+}
+
+type entry struct {
+	Account
+	sealed
+}
+
+// file is the on-disk format. Version 1 files have the sealed fields at the top level.
+type file struct {
+	Version  int     `json:"version"`
+	Accounts []entry `json:"accounts,omitempty"`
+	sealed
+}
+
+// Store is an encrypted token file holding any number of accounts, each with its own passphrase.
+type Store struct {
+	path string
+}
+
+func New(path string) *Store {
+	return &Store{path: path}
+}
+
+// Default stores the tokens under the user config dir, e.g. ~/.config/wipecord/token.json.
+func Default() (*Store, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return nil, err
+	}
+	return New(filepath.Join(dir, "wipecord", "token.json")), nil
+}
+
+func (s *Store) Path() string {
+	return s.path
+}
+
+// Exists reports whether at least one token has been saved.
+func (s *Store) Exists() bool {
+	accounts, err := s.Accounts()
+	return err == nil && len(accounts) > 0
+}
+
+// Accounts lists the saved accounts in the order they were first saved.
+func (s *Store) Accounts() ([]Account, error) {
+	f, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	accounts := make([]Account, len(f.Accounts))
+	for i, e := range f.Accounts {
+		accounts[i] = e.Account
+	}
+	return accounts, nil
+}
+
+// Save encrypts token with a key derived from passphrase and writes it atomically with mode 0600.
+// An account with the same user ID is replaced, keeping its place in the list.
+func (s *Store) Save(acc Account, token, passphrase string) error {
+	if passphrase == "" {
+		return ErrEmptyPassphrase
+	}
+	f, err := s.load()
+	if err != nil {
+		return err
+	}
+
+	e := entry{Account: acc, sealed: sealed{KDF: kdf, Iterations: Iterations, Salt: make([]byte, saltSize)}}
+	if _, err := rand.Read(e.Salt); err != nil {
+		return err
+	}
+	gcm, err := e.cipher(passphrase)
+	if err != nil {
+		return err
+	}
+	e.Nonce = make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(e.Nonce); err != nil {
+		return err
+	}
+	e.Ciphertext = gcm.Seal(nil, e.Nonce, []byte(token), nil)
+
+	if i := f.index(acc.UserID); i >= 0 {
+		f.Accounts[i] = e
+	} else {
+		f.Accounts = append(f.Accounts, e)
+	}
+	return s.write(f)
+}
+
+// Unlock decrypts the token saved for userID. A wrong passphrase gives ErrWrongPassphrase.
+func (s *Store) Unlock(userID, passphrase string) (string, error) {
+	f, err := s.load()
+	if err != nil {
+		return "", err
+	}
+	i := f.index(userID)
+	if i < 0 {
+		return "", ErrNoToken
+	}
+	e := f.Accounts[i]
+
+	gcm, err := e.cipher(passphrase)
+	if err != nil {
+		return "", err
+	}
+	if len(e.Nonce) != gcm.NonceSize() {
+		return "", fmt.Errorf("corrupt token file %s: bad nonce", s.path)
+	}
+	// GCM authenticates the ciphertext, so a wrong key fails here instead of returning garbage.
+	token, err := gcm.Open(nil, e.Nonce, e.Ciphertext, nil)
+	if err != nil {
+		return "", ErrWrongPassphrase
+	}
+	return string(token), nil
+}
+
+// Forget deletes the token saved for userID. Forgetting an unknown account is not an error.
+// The file is removed with the last account.
+func (s *Store) Forget(userID string) error {
+	f, err := s.load()
+	if err != nil {
+		return err
+	}
+	if i := f.index(userID); i >= 0 {
+		f.Accounts = append(f.Accounts[:i], f.Accounts[i+1:]...)
+	}
+	if len(f.Accounts) == 0 {
+		err := os.Remove(s.path)
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	return s.write(f)
+}
+
+// load reads the file, upgrading a version 1 file to a single unlabelled account.
+// A missing file is an empty store.
+func (s *Store) load() (*file, error) {
+	f := &file{Version: version}
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return f, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, f); err != nil {
+		return nil, fmt.Errorf("corrupt token file %s: %w", s.path, err)
+	}
+	switch f.Version {
+	case 1:
+		f.Accounts = []entry{{sealed: f.sealed}}
+		f.sealed = sealed{}
+		f.Version = version
+	case version:
+	default:
+		return nil, fmt.Errorf("unsupported token file %s (version %d)", s.path, f.Version)
+	}
+
+	for _, e := range f.Accounts {
+		if e.KDF != kdf {
+			return nil, fmt.Errorf("unsupported key derivation %q in %s", e.KDF, s.path)
+		}
+	}
+	return f, nil
+}
+
+func (s *Store) write(f *file) error {
+	data, err := json.Marshal(f)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o700); err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
+
+func (f *file) index(userID string) int {
+	for i, e := range f.Accounts {
+		if e.UserID == userID {
+			return i
+		}
+	}
+	return -1
+}
+
+func (e *sealed) cipher(passphrase string) (cipher.AEAD, error) {
+	if e.Iterations <= 0 || len(e.Salt) == 0 {
+		return nil, errors.New("corrupt token file: missing key parameters")
+	}
+	key, err := pbkdf2.Key(sha256.New, passphrase, e.Salt, e.Iterations, keySize)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
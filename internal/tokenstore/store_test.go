@@ -0,0 +1,109 @@
+package tokenstore
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSaveAndUnlock(t *testing.T) {
+	s := New(filepath.Join(t.TempDir(), "token.json"))
+	alice := Account{UserID: "1", Username: "alice"}
+	if err := s.Save(alice, "secret-token", "pass"); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(s.Path())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "secret-token") {
+		t.Error("token written in plain text")
+	}
+	if info, err := os.Stat(s.Path()); err != nil {
+		t.Error(err)
+	} else if info.Mode().Perm() != 0o600 {
+		t.Errorf("file mode = %v, want 0600", info.Mode().Perm())
+	}
+
+	token, err := s.Unlock("1", "pass")
+	if err != nil || token != "secret-token" {
+		t.Errorf("Unlock = %q, %v", token, err)
+	}
+	if _, err := s.Unlock("1", "wrong"); !errors.Is(err, ErrWrongPassphrase) {
+		t.Errorf("Unlock with a wrong passphrase = %v, want ErrWrongPassphrase", err)
+	}
+	if _, err := s.Unlock("2", "pass"); !errors.Is(err, ErrNoToken) {
+		t.Errorf("Unlock of an unknown account = %v, want ErrNoToken", err)
+	}
+	if err := s.Save(alice, "x", ""); !errors.Is(err, ErrEmptyPassphrase) {
+		t.Errorf("Save with an empty passphrase = %v", err)
+	}
+}
+
+func TestAccountsAndForget(t *testing.T) {
+	s := New(filepath.Join(t.TempDir(), "token.json"))
+	if s.Exists() {
+		t.Fatal("Exists before saving anything")
+	}
+	for _, acc := range []Account{{UserID: "1", Username: "alice"}, {UserID: "2", Username: "bob"}, {UserID: "1", Username: "alice2"}} {
+		if err := s.Save(acc, "tok"+acc.UserID, "pass"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	accounts, err := s.Accounts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Account{{UserID: "1", Username: "alice2"}, {UserID: "2", Username: "bob"}}
+	if len(accounts) != 2 || accounts[0] != want[0] || accounts[1] != want[1] {
+		t.Errorf("Accounts = %v, want %v", accounts, want)
+	}
+
+	if err := s.Forget("1"); err != nil {
+		t.Fatal(err)
+	}
+	if token, err := s.Unlock("2", "pass"); err != nil || token != "tok2" {
+		t.Errorf("Unlock after forgetting another account = %q, %v", token, err)
+	}
+	if err := s.Forget("2"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(s.Path()); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("file left after forgetting the last account: %v", err)
+	}
+	if err := s.Forget("3"); err != nil {
+		t.Errorf("Forget of an unknown account = %v", err)
+	}
+}
+
+func TestVersion1File(t *testing.T) {
+	dir := t.TempDir()
+	s := New(filepath.Join(dir, "token.json"))
+	if err := s.Save(Account{}, "old-token", "pass"); err != nil {
+		t.Fatal(err)
+	}
+	// Rewrite the file the way version 1 stored its single token.
+	f, err := s.load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(file{Version: 1, sealed: f.Accounts[0].sealed})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(s.Path(), data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	accounts, err := s.Accounts()
+	if err != nil || len(accounts) != 1 || accounts[0].Label() != "(saved token)" {
+		t.Fatalf("Accounts = %v, %v, want one unlabelled account", accounts, err)
+	}
+	if token, err := s.Unlock("", "pass"); err != nil || token != "old-token" {
+		t.Errorf("Unlock = %q, %v", token, err)
+	}
+}
@@ -0,0 +1,239 @@
+package tui
+
+import (
+	"fmt"
+
+	"purge/internal/discord"
+	"purge/internal/tokenstore"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// AccountPicker lists the saved accounts. Choosing one asks for its passphrase,
+// the last entry adds another account through the login screen.
+type AccountPicker struct {
+	store    *tokenstore.Store
+	accounts []tokenstore.Account
+	cursor   int
+
+	passphrase textinput.Model
+	unlocking  bool // Asking for the passphrase of accounts[cursor].
+	checking   bool // Decrypting and checking the token, input is ignored.
+	forgetting bool // Asking whether to forget accounts[cursor].
+
+	err           error
+	width, height int
+}
+
+func NewAccountPicker(store *tokenstore.Store) *AccountPicker {
+	pi := textinput.New()
+	pi.Placeholder = "Passphrase"
+	pi.EchoMode = textinput.EchoPassword
+
+	m := &AccountPicker{store: store, passphrase: pi}
+	m.reload()
+	return m
+}
+
+// unlockedMsg carries the client for an unlocked account, or why it couldn't be unlocked.
+type unlockedMsg struct {
+	client *discord.Client
+	err    error
+}
+
+func (m *AccountPicker) reload() {
+	accounts, err := m.store.Accounts()
+	if err != nil {
+		m.err = err
+	}
+	m.accounts = accounts
+	m.cursor = min(m.cursor, len(m.accounts))
+}
+
+func (m *AccountPicker) Init() tea.Cmd {
+	return textinput.Blink
+}
+
+func (m *AccountPicker) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width, m.height = msg.Width, msg.Height
+		return m, nil
+
+	case unlockedMsg:
+		m.checking = false
+		if msg.err != nil {
+			m.err = msg.err
+			m.passphrase.Reset()
+			return m, nil
+		}
+		return switchTo(NewDMSelector(msg.client), m.width, m.height)
+
+	case tea.KeyMsg:
+		if m.checking {
+			if msg.String() == "ctrl+c" {
+				return m, tea.Quit
+			}
+			return m, nil
+		}
+		if m.forgetting {
+			return m.updateForget(msg)
+		}
+		if m.unlocking {
+			return m.updateUnlock(msg)
+		}
+
+		switch msg.String() {
+		case "ctrl+c", "esc", "q":
+			return m, tea.Quit
+
+		case "up", "k":
+			if m.cursor > 0 {
+				m.cursor--
+			}
+
+		case "down", "j":
+			// One past the accounts is "Add account".
+			if m.cursor < len(m.accounts) {
+				m.cursor++
+			}
+
+		case "ctrl+f":
+			if m.cursor < len(m.accounts) {
+				m.err = nil
+				m.forgetting = true
+			}
+
+		case "enter":
+			if m.cursor == len(m.accounts) {
+				return switchTo(LoginModel(), m.width, m.height)
+			}
+			m.err = nil
+			m.unlocking = true
+			m.passphrase.Reset()
+			return m, m.passphrase.Focus()
+		}
+		return m, nil
+	}
+
+	return m, nil
+}
+
+func (m *AccountPicker) updateUnlock(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.Type {
+	case tea.KeyCtrlC:
+		return m, tea.Quit
+
+	case tea.KeyEsc:
+		m.unlocking = false
+		m.passphrase.Blur()
+		m.err = nil
+		return m, nil
+
+	case tea.KeyEnter:
+		// Deriving the key takes a moment on purpose, so it runs off the UI goroutine.
+		m.checking = true
+		m.err = nil
+		return m, unlock(m.store, m.accounts[m.cursor], m.passphrase.Value())
+	}
+
+	var cmd tea.Cmd
+	m.passphrase, cmd = m.passphrase.Update(msg)
+	return m, cmd
+}
+
+// unlock decrypts the token of acc and checks it with Discord.
+func unlock(store *tokenstore.Store, acc tokenstore.Account, pass string) tea.Cmd {
+	return func() tea.Msg {
+		token, err := store.Unlock(acc.UserID, pass)
+		if err != nil {
+			return unlockedMsg{err: err}
+		}
+		c, err := checkToken(token)
+		if err != nil {
+			return unlockedMsg{err: fmt.Errorf("%s: %w", acc.Label(), err)}
+		}
+
+		// Usernames change, and tokens saved by older versions have no label at all.
+		if cur := accountOf(c); cur != acc {
+			if err := store.Save(cur, token, pass); err == nil && cur.UserID != acc.UserID {
+				store.Forget(acc.UserID)
+			}
+		}
+		return unlockedMsg{client: c}
+	}
+}
+
+func (m *AccountPicker) updateForget(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+c":
+		return m, tea.Quit
+	case "y", "Y":
+		if err := m.store.Forget(m.accounts[m.cursor].UserID); err != nil {
+			m.err = err
+		}
+		m.reload()
+	case "n", "N", "esc":
+	default:
+		return m, nil
+	}
+	m.forgetting = false
+	return m, nil
+}
+
+func (m *AccountPicker) View() string {
+	menuStyle := lipgloss.NewStyle().
+		Padding(1, 2).
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(lipgloss.Color("129")).
+		Margin(1)
+	titleStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#6600CC")).Bold(true)
+	selectedStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("255")).Bold(true)
+	unselectedStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("240"))
+	errStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#FF0000"))
+
+	lines := []string{titleStyle.Render("Choose an account"), ""}
+	for i := 0; i <= len(m.accounts); i++ {
+		item := "+ Add account"
+		if i < len(m.accounts) {
+			acc := m.accounts[i]
+			item = acc.Label()
+			if acc.UserID != "" {
+				item += " (" + acc.UserID + ")"
+			}
+		}
+		if i == m.cursor {
+			lines = append(lines, selectedStyle.Render("> "+item+" <"))
+		} else {
+			lines = append(lines, unselectedStyle.Render("  "+item+"  "))
+		}
+	}
+
+	lines = append(lines, "")
+	switch {
+	case m.forgetting:
+		lines = append(lines,
+			titleStyle.Render("Forget "+m.accounts[m.cursor].Label()+"? Its saved token is deleted. [y/n]"))
+	case m.checking:
+		lines = append(lines, unselectedStyle.Render("Unlocking..."))
+	case m.unlocking:
+		lines = append(lines,
+			titleStyle.Render("Passphrase for "+m.accounts[m.cursor].Label()+":"),
+			m.passphrase.View(),
+			unselectedStyle.Render("[Enter] unlock   [Esc] back"))
+	default:
+		lines = append(lines, unselectedStyle.Render("[Enter] choose   [Ctrl+F] forget account   [Esc] quit"))
+	}
+
+	if m.err != nil {
+		lines = append(lines, errStyle.Render(m.err.Error()))
+	}
+
+	return lipgloss.Place(
+		m.width, m.height,
+		lipgloss.Center, lipgloss.Center,
+		menuStyle.Render(lipgloss.JoinVertical(lipgloss.Center, lines...)),
+	)
+}
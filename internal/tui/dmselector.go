@@ -1,9 +1,11 @@
 package tui
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"purge/internal/discord"
+	"purge/internal/purge"
 	"strings"
 
 	tea "github.com/charmbracelet/bubbletea"
@@ -13,20 +15,23 @@ import (
 // Todo: add winsize detection for the slicer
 
 type DMSelector struct {
-	options      []string
-	filtered     []string
-	cursor       int
-	sliceIndex   int
+	options  []string
+	filtered []string
+	listCursor
 	width        int
 	height       int
 	selectedDMID string
 	searchInput  string
+	picked       map[string]bool // Channel IDs ticked with space, purged one after another.
 	Client       *discord.Client
+
+	title string    // Shown above the list, e.g. the guild name.
+	back  tea.Model // Where Esc returns to, nil for the DM list.
 }
 
 func NewDMSelector(client *discord.Client) *DMSelector {
 
-	err := client.FetchDMS()
+	err := client.FetchDMS(context.Background())
 
 	if err != nil {
 		log.Fatalf("Failed to fetch DMs: %v", err)
@@ -35,29 +40,7 @@ func NewDMSelector(client *discord.Client) *DMSelector {
 	var options []string
 
 	for _, ch := range client.DMS {
-		var name string
-
-		switch ch.Type {
-		case 1: // DM
-			if len(ch.Recipients) > 0 {
-				name = ch.Recipients[0].Username
-			} else {
-				name = "(unknown DM)"
-			}
-		case 3: // Group DM
-			if ch.Name != "" {
-				name = ch.Name
-			} else {
-				var names []string
-				for _, r := range ch.Recipients {
-					names = append(names, r.Username)
-				}
-				name = "(Group: " + strings.Join(names, ", ") + ")"
-			}
-		default:
-			name = "(unknown channel type)"
-		}
-
+		name := ch.DisplayName()
 		option := fmt.Sprintf("%s: %s", name, ch.ID)
 		options = append(options, option)
 	}
@@ -66,7 +49,39 @@ func NewDMSelector(client *discord.Client) *DMSelector {
 		options:  options,
 		filtered: options,
 		Client:   client,
+		title:    "Direct Messages",
+	}
+}
+
+// NewChannelSelector lists the channels of guild that have messages, in sidebar order.
+// Picking one works like picking a DM; Esc goes back to back.
+func NewChannelSelector(client *discord.Client, guild discord.Guild, channels []discord.Channel, back tea.Model) *DMSelector {
+	var options []string
+	for _, ch := range discord.SortChannels(channels) {
+		if !ch.HasMessages() {
+			continue
+		}
+		options = append(options, fmt.Sprintf("%s: %s", channelLabel(ch, channels), ch.ID))
+	}
+
+	return &DMSelector{
+		options:  options,
+		filtered: options,
+		Client:   client,
+		title:    guild.Name,
+		back:     back,
+	}
+}
+
+// channelLabel names a guild channel with its category, e.g. "General / #chat".
+func channelLabel(ch discord.Channel, all []discord.Channel) string {
+	name := "#" + ch.Name
+	for _, parent := range all {
+		if parent.ID == ch.ParentID && parent.Type == discord.ChannelTypeGuildCategory {
+			return parent.Name + " / " + name
+		}
 	}
+	return name
 }
 
 func (m *DMSelector) Init() tea.Cmd {
@@ -84,39 +99,55 @@ func (m *DMSelector) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		case "ctrl+c", "q":
 			return m, tea.Quit
 
-		case "up", "k":
-			if m.cursor > 0 {
-				m.cursor--
-			} else if m.sliceIndex > 0 {
-				m.sliceIndex--
-			} else {
-				m.sliceIndex = max(0, len(m.filtered)-25)
-				m.cursor = min(24, len(m.filtered)-1)
+		case "esc":
+			if m.back != nil {
+				return switchTo(m.back, m.width, m.height)
 			}
 
-		case "down", "j":
-			if m.cursor < 24 && m.sliceIndex+m.cursor+1 < len(m.filtered) {
-				m.cursor++
-			} else if m.sliceIndex+25 < len(m.filtered) {
-				m.sliceIndex++
-			} else {
-				m.sliceIndex = 0
-				m.cursor = 0
+		case "ctrl+g":
+			if m.back == nil {
+				return switchTo(NewGuildSelector(m.Client, m), m.width, m.height)
 			}
 
+		case "up", "k":
+			m.up(len(m.filtered))
+
+		case "down", "j":
+			m.down(len(m.filtered))
+
 		case "backspace":
 			if len(m.searchInput) > 0 {
 				m.searchInput = m.searchInput[:len(m.searchInput)-1]
 				m.updateFiltered()
 			}
 
-		case "enter":
-			if m.cursor < len(m.filtered) {
-				selected := m.filtered[m.cursor]
-				parts := strings.Split(selected, ": ")
-				if len(parts) == 2 {
-					m.selectedDMID = parts[1]
+		case " ":
+			if _, id, ok := m.selected(); ok {
+				m.toggle(id, !m.picked[id])
+			}
+
+		case "ctrl+t":
+			// Tick everything the search shows, or untick it if it is all ticked already.
+			all := true
+			for _, option := range m.filtered {
+				if _, id, ok := splitOption(option); ok && !m.picked[id] {
+					all = false
+				}
+			}
+			for _, option := range m.filtered {
+				if _, id, ok := splitOption(option); ok {
+					m.toggle(id, !all)
 				}
+			}
+
+		case "enter":
+			if jobs := m.jobs(); len(jobs) > 0 {
+				settings := NewSettingsModel(m.Client)
+				settings.SetJobs(jobs)
+				return switchTo(settings, m.width, m.height)
+			}
+			if _, id, ok := m.selected(); ok {
+				m.selectedDMID = id
 
 				settings := NewSettingsModel(m.Client)
 				settings.SetChannelID(m.selectedDMID)
@@ -125,6 +156,18 @@ func (m *DMSelector) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 			}
 
+		case "ctrl+a":
+			// Switch accounts without restarting.
+			return switchTo(New(), m.width, m.height)
+
+		case "ctrl+e":
+			if name, id, ok := m.selected(); ok {
+				exp := NewExportModel(m.Client, id, name, m)
+				return exp, tea.Batch(exp.Init(), func() tea.Msg {
+					return tea.WindowSizeMsg{Width: m.width, Height: m.height}
+				})
+			}
+
 		default:
 			if len(msg.String()) == 1 {
 				m.searchInput += msg.String()
@@ -135,6 +178,46 @@ func (m *DMSelector) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	return m, nil
 }
 
+// selected returns the name and channel ID of the highlighted option.
+func (m *DMSelector) selected() (name, id string, ok bool) {
+	i := m.index()
+	if i >= len(m.filtered) {
+		return "", "", false
+	}
+	return splitOption(m.filtered[i])
+}
+
+func (m *DMSelector) toggle(id string, on bool) {
+	if m.picked == nil {
+		m.picked = make(map[string]bool)
+	}
+	if on {
+		m.picked[id] = true
+	} else {
+		delete(m.picked, id)
+	}
+}
+
+// jobs lists the ticked channels in the order they are shown, including ones hidden by the search.
+func (m *DMSelector) jobs() []purge.Job {
+	var jobs []purge.Job
+	for _, option := range m.options {
+		if name, id, ok := splitOption(option); ok && m.picked[id] {
+			jobs = append(jobs, purge.Job{ChannelID: id, Name: name})
+		}
+	}
+	return jobs
+}
+
+// splitOption splits an option of the form "name: id".
+func splitOption(option string) (name, id string, ok bool) {
+	sep := strings.LastIndex(option, ": ")
+	if sep < 0 {
+		return "", "", false
+	}
+	return option[:sep], option[sep+2:], true
+}
+
 func (m *DMSelector) updateFiltered() {
 	if m.searchInput == "" {
 		m.filtered = m.options
@@ -173,7 +256,7 @@ func sliceWindow(original []string, start, windowSize int) []string {
 }
 
 func (m *DMSelector) View() string {
-	items := sliceWindow(m.filtered, m.sliceIndex, 25)
+	items := sliceWindow(m.filtered, m.sliceIndex, listRows)
 
 	menuStyle := lipgloss.NewStyle().
 		Padding(1, 2).
@@ -188,10 +271,24 @@ func (m *DMSelector) View() string {
 	unselectedStyle := lipgloss.NewStyle().
 		Foreground(lipgloss.Color("240"))
 
-	header := lipgloss.NewStyle().Bold(true).Render("Search: " + m.searchInput)
+	title := m.title
+	if len(m.picked) > 0 {
+		title += fmt.Sprintf(" (%d selected)", len(m.picked))
+	}
+	header := lipgloss.NewStyle().Bold(true).Render(title + "\nSearch: " + m.searchInput)
+	help := "[Enter] purge   [Space] select   [Ctrl+T] select all   [Ctrl+E] export transcript\n[Ctrl+G] servers   [Ctrl+A] switch account"
+	if m.back != nil {
+		help = "[Enter] purge   [Space] select   [Ctrl+T] select all   [Ctrl+E] export transcript   [Esc] back"
+	}
+	help = unselectedStyle.Render(help)
 
 	var menuItems []string
 	for i, item := range items {
+		if _, id, ok := splitOption(item); ok && m.picked[id] {
+			item = "[x] " + item
+		} else if len(m.picked) > 0 {
+			item = "[ ] " + item
+		}
 		if i == m.cursor {
 			menuItems = append(menuItems, selectedStyle.Render("> "+item+" <"))
 		} else {
@@ -201,7 +298,7 @@ func (m *DMSelector) View() string {
 
 	menuContent := lipgloss.JoinVertical(
 		lipgloss.Center,
-		append([]string{header}, menuItems...)...,
+		append(append([]string{header}, menuItems...), help)...,
 	)
 
 	return lipgloss.Place(
@@ -0,0 +1,41 @@
+package tui
+
+import (
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func keys(m tea.Model, s string) tea.Model {
+	for _, r := range s {
+		msg := tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{r}}
+		if r == ' ' {
+			msg = tea.KeyMsg{Type: tea.KeySpace, Runes: []rune{r}}
+		}
+		m, _ = m.Update(msg)
+	}
+	return m
+}
+
+func TestDMSelectorSpaceTicksWhileSearching(t *testing.T) {
+	options := []string{"alice: 10", "bob: 11", "carol: 12"}
+	m := &DMSelector{options: options, filtered: options}
+
+	keys(m, " ")
+	if !m.picked["10"] || len(m.picked) != 1 {
+		t.Fatalf("picked %v, want 10", m.picked)
+	}
+
+	keys(m, "bo ")
+	if m.searchInput != "bo" {
+		t.Errorf("search = %q, want the space left out", m.searchInput)
+	}
+	if !m.picked["11"] || len(m.picked) != 2 {
+		t.Errorf("picked %v, want 10 and 11", m.picked)
+	}
+
+	keys(m, " ")
+	if m.picked["11"] {
+		t.Error("a second Space didn't untick bob")
+	}
+}
@@ -0,0 +1,165 @@
+package tui
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"purge/internal/discord"
+	"purge/internal/export"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+type exportProgressMsg int
+
+type exportDoneMsg struct {
+	htmlPath, mdPath string
+	count            int
+}
+
+// ExportModel writes an HTML and Markdown transcript of one channel.
+type ExportModel struct {
+	client        *discord.Client
+	back          tea.Model
+	channelID     string
+	title         string
+	width, height int
+
+	msgChan chan tea.Msg
+	cancel  context.CancelFunc
+	fetched int
+	result  *exportDoneMsg
+	err     error
+}
+
+// NewExportModel exports channelID and returns to back when done.
+func NewExportModel(client *discord.Client, channelID, title string, back tea.Model) *ExportModel {
+	return &ExportModel{
+		client:    client,
+		back:      back,
+		channelID: channelID,
+		title:     title,
+	}
+}
+
+func (m *ExportModel) Init() tea.Cmd {
+	ctx, cancel := context.WithCancel(context.Background())
+	m.cancel = cancel
+	m.msgChan = make(chan tea.Msg)
+
+	go func() {
+		defer close(m.msgChan)
+		defer cancel()
+
+		msgs, err := export.NewExporter(m.client).Fetch(ctx, m.channelID, func(n int) {
+			m.msgChan <- exportProgressMsg(n)
+		})
+		if err != nil {
+			m.msgChan <- errMsg(err)
+			return
+		}
+
+		dir, err := export.DefaultDir()
+		if err != nil {
+			m.msgChan <- errMsg(err)
+			return
+		}
+		htmlPath, mdPath, err := export.WriteFiles(dir, export.Transcript{
+			Title:     m.title,
+			ChannelID: m.channelID,
+			Exported:  time.Now(),
+			Messages:  msgs,
+		})
+		if err != nil {
+			m.msgChan <- errMsg(err)
+			return
+		}
+		m.msgChan <- exportDoneMsg{htmlPath: htmlPath, mdPath: mdPath, count: len(msgs)}
+	}()
+
+	return m.waitForMsg()
+}
+
+func (m *ExportModel) waitForMsg() tea.Cmd {
+	return func() tea.Msg {
+		if msg, ok := <-m.msgChan; ok {
+			return msg
+		}
+		return nil
+	}
+}
+
+func (m *ExportModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width, m.height = msg.Width, msg.Height
+
+	case tea.KeyMsg:
+		switch msg.Type {
+		case tea.KeyCtrlC:
+			m.cancel()
+			return m, tea.Quit
+		case tea.KeyEsc, tea.KeyEnter:
+			// Esc cancels a running export; either key goes back once it has finished.
+			if m.result == nil && m.err == nil {
+				if msg.Type == tea.KeyEsc {
+					m.cancel()
+				}
+				return m, nil
+			}
+			return m.back, func() tea.Msg {
+				return tea.WindowSizeMsg{Width: m.width, Height: m.height}
+			}
+		}
+
+	case exportProgressMsg:
+		m.fetched = int(msg)
+		return m, m.waitForMsg()
+
+	case exportDoneMsg:
+		m.result = &msg
+		return m, nil
+
+	case errMsg:
+		m.err = msg
+		return m, nil
+	}
+	return m, nil
+}
+
+func (m *ExportModel) View() string {
+	labelStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#FF0AFF")).Bold(true)
+	valueStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#BA55D3"))
+	errStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#FF3333")).Bold(true)
+
+	lines := []string{
+		fmt.Sprintf("%s %s", labelStyle.Render("Exporting:"), valueStyle.Render(m.title)),
+		fmt.Sprintf("%s %s", labelStyle.Render("Fetched:"), valueStyle.Render(fmt.Sprintf("%d", m.fetched))),
+	}
+
+	switch {
+	case m.err != nil:
+		lines = append(lines, errStyle.Render("Error: "+m.err.Error()), labelStyle.Render("[Enter] to go back"))
+	case m.result != nil:
+		lines = append(lines,
+			fmt.Sprintf("%s %s", labelStyle.Render("HTML:"), valueStyle.Render(m.result.htmlPath)),
+			fmt.Sprintf("%s %s", labelStyle.Render("Markdown:"), valueStyle.Render(m.result.mdPath)),
+			labelStyle.Render("[Enter] to go back"))
+	default:
+		lines = append(lines, labelStyle.Render("[Esc] to cancel"))
+	}
+
+	container := lipgloss.NewStyle().
+		Padding(1, 3).
+		Border(lipgloss.NormalBorder()).
+		BorderForeground(lipgloss.Color("129")).
+		Render(lipgloss.JoinVertical(lipgloss.Left, lines...))
+
+	return lipgloss.Place(
+		m.width, m.height,
+		lipgloss.Center, lipgloss.Center,
+		container,
+	)
+}
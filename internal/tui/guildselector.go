@@ -0,0 +1,138 @@
+package tui
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"purge/internal/discord"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// GuildSelector lists the servers the user is in. Picking one opens its channels.
+type GuildSelector struct {
+	Client   *discord.Client
+	guilds   []discord.Guild
+	filtered []discord.Guild
+	listCursor
+	searchInput string
+	back        tea.Model
+
+	err           error
+	width, height int
+}
+
+func NewGuildSelector(client *discord.Client, back tea.Model) *GuildSelector {
+	m := &GuildSelector{Client: client, back: back}
+	m.guilds, m.err = client.FetchGuilds(context.Background())
+	m.filtered = m.guilds
+	return m
+}
+
+func (m *GuildSelector) Init() tea.Cmd {
+	return nil
+}
+
+func (m *GuildSelector) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width, m.height = msg.Width, msg.Height
+
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "ctrl+c":
+			return m, tea.Quit
+
+		case "esc":
+			return switchTo(m.back, m.width, m.height)
+
+		case "up", "k":
+			m.up(len(m.filtered))
+
+		case "down", "j":
+			m.down(len(m.filtered))
+
+		case "backspace":
+			if len(m.searchInput) > 0 {
+				m.searchInput = m.searchInput[:len(m.searchInput)-1]
+				m.updateFiltered()
+			}
+
+		case "enter":
+			i := m.index()
+			if i >= len(m.filtered) {
+				return m, nil
+			}
+			g := m.filtered[i]
+			channels, err := m.Client.FetchGuildChannels(context.Background(), g.ID)
+			if err != nil {
+				m.err = fmt.Errorf("%s: %w", g.Name, err)
+				return m, nil
+			}
+			return switchTo(NewChannelSelector(m.Client, g, channels, m), m.width, m.height)
+
+		case "ctrl+p":
+			i := m.index()
+			if i >= len(m.filtered) {
+				return m, nil
+			}
+			settings := NewSettingsModel(m.Client)
+			settings.SetGuild(m.filtered[i])
+			return switchTo(settings, m.width, m.height)
+
+		default:
+			if len(msg.String()) == 1 {
+				m.searchInput += msg.String()
+				m.updateFiltered()
+			}
+		}
+	}
+	return m, nil
+}
+
+func (m *GuildSelector) updateFiltered() {
+	m.filtered = nil
+	for _, g := range m.guilds {
+		if strings.Contains(strings.ToLower(g.Name+" "+g.ID), strings.ToLower(m.searchInput)) {
+			m.filtered = append(m.filtered, g)
+		}
+	}
+	m.cursor = 0
+	m.sliceIndex = 0
+}
+
+func (m *GuildSelector) View() string {
+	menuStyle := lipgloss.NewStyle().
+		Padding(1, 2).
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(lipgloss.Color("129")).
+		Margin(1)
+	selectedStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("255")).Bold(true)
+	unselectedStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("240"))
+	errStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#FF3333")).Bold(true)
+
+	lines := []string{lipgloss.NewStyle().Bold(true).Render("Servers\nSearch: " + m.searchInput)}
+
+	end := min(m.sliceIndex+listRows, len(m.filtered))
+	for i, g := range m.filtered[m.sliceIndex:end] {
+		item := fmt.Sprintf("%s: %s", g.Name, g.ID)
+		if i == m.cursor {
+			lines = append(lines, selectedStyle.Render("> "+item+" <"))
+		} else {
+			lines = append(lines, unselectedStyle.Render("  "+item+"  "))
+		}
+	}
+
+	lines = append(lines, unselectedStyle.Render("[Enter] channels   [Ctrl+P] purge whole server   [Esc] back to DMs"))
+	if m.err != nil {
+		lines = append(lines, errStyle.Render("Error: "+m.err.Error()))
+	}
+
+	return lipgloss.Place(
+		m.width, m.height,
+		lipgloss.Center, lipgloss.Center,
+		menuStyle.Render(lipgloss.JoinVertical(lipgloss.Center, lines...)),
+	)
+}
@@ -0,0 +1,42 @@
+package tui
+
+// listRows is how many rows of a list selector are shown at once.
+const listRows = 25
+
+// listCursor is the highlighted row of a list shown listRows at a time: cursor is the row
+// on screen and sliceIndex the first item shown. Up and down wrap around at either end.
+type listCursor struct {
+	cursor     int
+	sliceIndex int
+}
+
+// up moves to the previous of n items, or from the first to the last.
+func (l *listCursor) up(n int) {
+	switch {
+	case l.cursor > 0:
+		l.cursor--
+	case l.sliceIndex > 0:
+		l.sliceIndex--
+	case n > 0:
+		l.sliceIndex = max(0, n-listRows)
+		l.cursor = min(listRows, n) - 1
+	}
+}
+
+// down moves to the next of n items, or from the last back to the first.
+func (l *listCursor) down(n int) {
+	switch {
+	case l.cursor < listRows-1 && l.sliceIndex+l.cursor+1 < n:
+		l.cursor++
+	case l.sliceIndex+listRows < n:
+		l.sliceIndex++
+	default:
+		l.sliceIndex = 0
+		l.cursor = 0
+	}
+}
+
+// index is the position of the highlighted item in the list.
+func (l *listCursor) index() int {
+	return l.sliceIndex + l.cursor
+}
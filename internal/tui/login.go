@@ -1,7 +1,11 @@
 package tui
 
 import (
+	"errors"
+	"fmt"
+
 	"purge/internal/discord"
+	"purge/internal/tokenstore"
 
 	"github.com/charmbracelet/bubbles/textinput"
 	tea "github.com/charmbracelet/bubbletea"
@@ -12,24 +16,72 @@ type (
 	errMsg error
 )
 
+// loginStage is what the single input on the login screen is currently asking for.
+type loginStage int
+
+const (
+	stageToken   loginStage = iota // Paste a token.
+	stageSave                      // Passphrase to save the token that was just checked.
+	stageConfirm                   // The same passphrase again.
+)
+
+// savedMsg reports that the token was encrypted and saved, or why it wasn't.
+type savedMsg struct{ err error }
+
 type model struct {
 	textInput textinput.Model
+	store     *tokenstore.Store // Nil if there is no config dir.
+	stage     loginStage
+	save      bool            // Save the token once it is checked.
+	client    *discord.Client // Checked client, kept while asking for the save passphrase.
+	pass      string          // Passphrase typed in stageSave, to compare in stageConfirm.
+	saving    bool            // Deriving the key and writing the file, input is ignored.
+	accounts  bool            // The store had saved accounts when the screen opened.
+	status    string
 	err       error
 	width     int
 	height    int
 }
 
+// New returns the first screen: the account picker if any accounts are saved, the login screen otherwise.
+func New() tea.Model {
+	if store, err := tokenstore.Default(); err == nil && store.Exists() {
+		return NewAccountPicker(store)
+	}
+	return LoginModel()
+}
+
 func LoginModel() *model {
 	ti := textinput.New()
-	ti.Placeholder = "Token"
 	ti.Focus()
 	ti.CharLimit = 156
 	//ti.Width = 50
 
-	return &model{
+	m := &model{
 		textInput: ti,
 		err:       nil,
 	}
+
+	if store, err := tokenstore.Default(); err == nil {
+		m.store = store
+		m.accounts = store.Exists()
+	}
+	m.setStage(stageToken)
+	return m
+}
+
+func (m *model) setStage(s loginStage) {
+	m.stage = s
+	m.textInput.Reset()
+
+	switch s {
+	case stageToken:
+		m.textInput.Placeholder = "Token"
+		m.textInput.EchoMode = textinput.EchoNormal
+	case stageSave, stageConfirm:
+		m.textInput.Placeholder = "Passphrase"
+		m.textInput.EchoMode = textinput.EchoPassword
+	}
 }
 
 func (m *model) Init() tea.Cmd {
@@ -43,23 +95,42 @@ func (m *model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	case tea.WindowSizeMsg:
 		m.width = msg.Width
 		m.height = msg.Height
+	case savedMsg:
+		m.saving = false
+		if msg.err != nil {
+			m.err = fmt.Errorf("saving token: %w", msg.err)
+			m.status = ""
+			m.setStage(stageSave)
+			return m, nil
+		}
+		return switchTo(NewDMSelector(m.client), m.width, m.height)
+
 	case tea.KeyMsg:
+		if m.saving {
+			if msg.Type == tea.KeyCtrlC {
+				return m, tea.Quit
+			}
+			return m, nil
+		}
 		switch msg.Type {
-		case tea.KeyCtrlC, tea.KeyEsc:
+		case tea.KeyCtrlC:
 			return m, tea.Quit
 
-		case tea.KeyEnter:
-			//TokenCheck
-			value := m.textInput.Value()
-			c := discord.NewClient(value)
-			err := c.TokenCheck()
-			if err != nil {
-				m.err = err
-				return m, nil
+		case tea.KeyEsc:
+			// Back to the saved accounts, if there are any to go back to.
+			if m.accounts {
+				return switchTo(NewAccountPicker(m.store), m.width, m.height)
 			}
-			MainMenu := NewDMSelector(c)
-			return MainMenu, nil
+			return m, tea.Quit
 
+		case tea.KeyCtrlS:
+			if m.stage == stageToken && m.store != nil {
+				m.save = !m.save
+			}
+			return m, nil
+
+		case tea.KeyEnter:
+			return m.submit()
 		}
 
 	case errMsg:
@@ -71,18 +142,116 @@ func (m *model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	return m, cmd
 }
 
+func (m *model) submit() (tea.Model, tea.Cmd) {
+	value := m.textInput.Value()
+
+	switch m.stage {
+	case stageSave:
+		if value == "" {
+			// An empty passphrase skips saving.
+			return NewDMSelector(m.client), nil
+		}
+		m.pass = value
+		m.err = nil
+		m.setStage(stageConfirm)
+		return m, nil
+
+	case stageConfirm:
+		if value != m.pass {
+			m.err = errors.New("passphrases don't match, try again")
+			m.pass = ""
+			m.setStage(stageSave)
+			return m, nil
+		}
+		// Deriving the key takes a moment on purpose, so it runs off the UI goroutine.
+		m.saving = true
+		m.err = nil
+		m.status = "Encrypting token..."
+		store, acc, token, pass := m.store, accountOf(m.client), m.client.Token, m.pass
+		m.pass = ""
+		return m, func() tea.Msg {
+			return savedMsg{store.Save(acc, token, pass)}
+		}
+	}
+
+	//TokenCheck
+	c, err := checkToken(value)
+	if err != nil {
+		m.err = err
+		return m, nil
+	}
+	if m.save {
+		m.client = c
+		m.err = nil
+		m.status = "Logged in as " + c.UserInfo.Username
+		m.setStage(stageSave)
+		return m, nil
+	}
+	MainMenu := NewDMSelector(c)
+	return MainMenu, nil
+}
+
+// checkToken validates token and fetches the profile used to label the account.
+func checkToken(token string) (*discord.Client, error) {
+	if token == "" {
+		return nil, errors.New("token is empty")
+	}
+	c := discord.NewClient(token)
+	if err := c.TokenCheck(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func accountOf(c *discord.Client) tokenstore.Account {
+	return tokenstore.Account{UserID: c.UserInfo.ID, Username: c.UserInfo.Username}
+}
+
+// switchTo hands over to next, passing on the window size it would otherwise never see.
+func switchTo(next tea.Model, width, height int) (tea.Model, tea.Cmd) {
+	return next, tea.Batch(next.Init(), func() tea.Msg {
+		return tea.WindowSizeMsg{Width: width, Height: height}
+	})
+}
+
 func (m *model) View() string {
 
 	titlestyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#6600CC")).Bold(true)
 	infoStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#6600CC")).MarginTop(1).Align(lipgloss.Center)
 	errorStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#FF0000")).MarginTop(1).Align(lipgloss.Center)
 
+	var title, info string
+	switch m.stage {
+	case stageSave:
+		title = "Choose a passphrase to encrypt the token:"
+		info = "Press Enter to continue, or leave empty to skip"
+	case stageConfirm:
+		title = "Enter the passphrase again:"
+		info = "Press Enter to save"
+	default:
+		title = "Enter Discord Token:"
+		info = "Press Enter to continue"
+		if m.store != nil {
+			box := "[ ]"
+			if m.save {
+				box = "[x]"
+			}
+			info += "\n[Ctrl+S] " + box + " save token encrypted"
+			if m.accounts {
+				info += "\n[Esc] back to saved accounts"
+			}
+		}
+	}
+
 	components := []string{
-		titlestyle.Render("Enter Discord Token:"),
+		titlestyle.Render(title),
 		m.textInput.View(),
-		infoStyle.Render("Press Enter to continue"),
+		infoStyle.Render(info),
 	}
 
+	if m.status != "" {
+		components = append(components, infoStyle.Render(m.status))
+	}
 	if m.err != nil {
 		components = append(components, errorStyle.Render(m.err.Error()))
 	}
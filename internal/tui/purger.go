@@ -1,9 +1,12 @@
 package tui
 
 import (
+	"context"
 	"fmt"
+	"strings"
 	"time"
 
+	"purge/internal/archive"
 	"purge/internal/discord"
 	"purge/internal/purge"
 
@@ -17,16 +20,49 @@ type PurgeModel struct {
 	dmid          string
 	Client        *discord.Client
 	msgChan       chan tea.Msg
+	cancel        context.CancelFunc
+	purger        *purge.Purger
+	GuildID       string            // Purge every channel and thread of this server instead of dmid.
+	Jobs          []purge.Job       // Purge these channels one after another instead of dmid.
+	ArchiveName   string            // Names the archive and report, see archive.Path. Defaults to dmid.
+	pending       *purge.Checkpoint // Found on start, waiting for the user to resume or discard it.
+
+	Filter      string
+	SearchDelay time.Duration
+	DeleteDelay time.Duration
+	MaxAttempts int
+	DryRun      bool
+	Search      bool
+	Archive     bool
+	archivePath string
+	archiver    archive.Writer // Opened by openArchive, closed when the purge ends.
+
+	DownloadAttachments bool
+	MaxAttachmentSize   int64 // Bytes, 0 for no limit.
+	Report              bool
+	reportPath          string
+	recorder            archive.Recorder
+	After               time.Time
+	Before              time.Time
+	deletedCount        int
+	failedCount         int
+	lastDeleted         string
+	channel             string // Current channel of a server or queued purge, e.g. "3/12 #general".
+	channelDeleted      int    // Counts of the current channel alone.
+	channelFailed       int
+	channelsDone        int
+	channelTotal        int
+	timeout             time.Duration
+	status              string
+	connecting          bool // Waiting for NewPurger.
+	stopping            bool
+	done                bool
+}
 
-	Filters      []string
-	SearchDelay  time.Duration
-	DeleteDelay  time.Duration
-	deletedCount int
-	failedCount  int
-	lastDeleted  string
-	timeout      time.Duration
-	status       string
-	done         bool
+// purgerReadyMsg carries the result of NewPurger, which runs as a command.
+type purgerReadyMsg struct {
+	purger *purge.Purger
+	err    error
 }
 
 func NewPurgeModel(DMID string, client *discord.Client) *PurgeModel {
@@ -57,6 +93,92 @@ func (m *PurgeModel) waitForMsg() tea.Cmd {
 	}
 }
 
+func (m *PurgeModel) archiveName() string {
+	if m.ArchiveName != "" {
+		return m.ArchiveName
+	}
+	return m.dmid
+}
+
+// openArchive sets the purger's archive from the Archive and DownloadAttachments options.
+func (m *PurgeModel) openArchive() error {
+	var writers []archive.Writer
+
+	if m.Archive {
+		dir, err := archive.DefaultDir()
+		if err != nil {
+			return err
+		}
+		m.archivePath = archive.Path(dir, m.archiveName(), archive.JSONL)
+		w, err := archive.OpenFile(m.archivePath, archive.JSONL)
+		if err != nil {
+			return err
+		}
+		writers = append(writers, w)
+	}
+
+	if m.DownloadAttachments {
+		dir, err := archive.DefaultAttachmentDir()
+		if err == nil {
+			var d *archive.Downloader
+			if d, err = archive.NewDownloader(dir, m.MaxAttachmentSize); err == nil {
+				writers = append(writers, d)
+			}
+		}
+		if err != nil {
+			archive.Multi(writers...).Close()
+			return err
+		}
+		if m.archivePath == "" {
+			m.archivePath = dir
+		}
+	}
+
+	if len(writers) > 0 {
+		m.archiver = archive.Multi(writers...)
+		m.purger.SetArchive(m.archiver)
+	}
+	return nil
+}
+
+func (m *PurgeModel) start() tea.Cmd {
+	m.msgChan = make(chan tea.Msg)
+	m.status = "Starting purge..."
+
+	ctx, cancel := context.WithCancel(context.Background())
+	m.cancel = cancel
+
+	go func() {
+		defer cancel()
+		if m.archiver != nil {
+			defer m.archiver.Close()
+		}
+		if m.recorder != nil {
+			defer m.recorder.Close()
+		}
+
+		push := func(u purge.Update) {
+			m.msgChan <- u
+		}
+		var err error
+		switch {
+		case m.GuildID != "":
+			err = m.purger.PurgeGuild(ctx, m.GuildID, push)
+		case len(m.Jobs) > 0:
+			err = m.purger.PurgeQueue(ctx, m.Jobs, push)
+		default:
+			err = m.purger.Purge(ctx, m.dmid, push)
+		}
+
+		if err != nil && !purge.IsStopped(err) {
+			m.msgChan <- errMsg(err)
+		}
+		close(m.msgChan)
+	}()
+
+	return m.waitForMsg()
+}
+
 func (m *PurgeModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
 
@@ -66,99 +188,278 @@ func (m *PurgeModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 	case tea.KeyMsg:
 		switch msg.Type {
-		case tea.KeyEsc, tea.KeyCtrlC:
+		case tea.KeyCtrlC:
+			if m.cancel != nil {
+				m.cancel()
+			}
 			return m, tea.Quit
 
-		case tea.KeyEnter:
-			if m.msgChan != nil || m.done {
+		case tea.KeyEsc:
+			// First Esc stops a running purge cleanly, the next one quits.
+			if m.cancel != nil && !m.done && !m.stopping {
+				m.cancel()
+				m.stopping = true
+				m.status = "Stopping..."
 				return m, nil
 			}
+			return m, tea.Quit
 
-			m.msgChan = make(chan tea.Msg)
-			m.status = "Starting purge..."
-
-			go func() {
-
-				purger, _ := purge.NewPurger(m.Client)
-
-				if len(m.Filters) > 0 {
-					purger.SetFilters(m.Filters)
-				}
-
-				if m.SearchDelay > 0 {
-					purger.SetSearchDelay(m.SearchDelay)
+		case tea.KeyRunes:
+			if m.pending != nil {
+				switch msg.String() {
+				case "y", "Y":
+					m.purger.ResumeFrom(m.pending)
+					m.deletedCount = m.pending.Deleted
+					m.failedCount = m.pending.Failed
+				case "n", "N":
+				default:
+					return m, nil
 				}
+				m.pending = nil
+				return m, m.start()
+			}
 
-				if m.DeleteDelay > 0 {
-					purger.SetDeleteDelay(m.DeleteDelay)
-				}
+			if (msg.String() != "p" && msg.String() != "P") || m.msgChan == nil || m.done || m.stopping {
+				return m, nil
+			}
+			// The purger reports the actual pause once it reaches a safe point.
+			if m.purger.Paused() {
+				m.purger.Resume()
+				m.status = "Resuming..."
+			} else {
+				m.purger.Pause()
+				m.status = "Pausing after current request..."
+			}
+			return m, nil
 
-				err := purger.Purge(m.dmid, func(u purge.Update) {
-					m.msgChan <- u
-				})
+		case tea.KeyEnter:
+			if m.done {
+				return m, tea.Quit
+			}
+			if m.purger != nil || m.connecting {
+				return m, nil
+			}
 
-				if err != nil {
-					m.msgChan <- errMsg(err)
-				}
-				close(m.msgChan)
-			}()
+			// NewPurger asks Discord who the user is, which mustn't hold up the UI.
+			m.connecting = true
+			m.status = "Connecting..."
+			client := m.Client
+			return m, func() tea.Msg {
+				purger, err := purge.NewPurger(client)
+				return purgerReadyMsg{purger: purger, err: err}
+			}
+		}
 
-			return m, m.waitForMsg()
+	case purgerReadyMsg:
+		m.connecting = false
+		if msg.err != nil {
+			m.err = msg.err
+			m.done = true
+			return m, nil
 		}
+		return m.setup(msg.purger)
 
 	case errMsg:
 		m.err = msg
 		m.done = true
 		return m, tea.Quit
 
-	case purge.Update:
+	// purge.Update is any, so the concrete types are listed; anything else
+	// (blinks, mouse events) must not read from msgChan.
+	case purge.UpdateDeleted, purge.UpdateFailed, purge.UpdateRateLimited,
+		purge.UpdateMatched, purge.UpdateInfo, purge.UpdatePaused,
+		purge.UpdateResumed, purge.UpdateChannel, purge.UpdateChannelDone,
+		purge.UpdateDone:
 		switch u := msg.(type) {
 
 		case purge.UpdateDeleted:
 			m.lastDeleted = truncate(u.Content, 50)
 			m.deletedCount++
+			m.channelDeleted++
 
 		case purge.UpdateFailed:
 			m.failedCount++
+			m.channelFailed++
 			m.status = truncate(u.Message, 60)
 
 		case purge.UpdateRateLimited:
 			m.timeout = u.Timeout
 			m.status = fmt.Sprintf("Rate limited. Waiting %s", u.Timeout)
 
+		case purge.UpdateMatched:
+			m.lastDeleted = truncate(u.Content, 50)
+			m.deletedCount++
+			m.channelDeleted++
+
+		case purge.UpdateInfo:
+			m.status = truncate(u.Message, 60)
+
+		case purge.UpdatePaused:
+			m.status = "Paused. Press [P] to resume"
+
+		case purge.UpdateResumed:
+			m.status = "Resumed"
+
+		case purge.UpdateChannel:
+			// DM names are shown as they are, server channels get a "#".
+			name := u.Name
+			if name == "" {
+				name = u.ChannelID
+			} else if m.GuildID != "" {
+				name = "#" + name
+			}
+			m.channel = fmt.Sprintf("%d/%d %s", u.Index, u.Total, name)
+			m.channelTotal = u.Total
+			m.channelDeleted, m.channelFailed = 0, 0
+
+		case purge.UpdateChannelDone:
+			m.channelsDone++
+
 		case purge.UpdateDone:
 			m.done = true
 			m.deletedCount = u.Deleted
 			m.failedCount = u.Failed
+			verb := "completed"
+			if u.Stopped {
+				verb = "stopped"
+			}
+			if u.DryRun {
+				m.deletedCount = u.Matched
+				m.status = fmt.Sprintf("Dry run %s. Would delete %d messages, %d attachments", verb, u.Matched, u.Attachments)
+				if u.Matched > 0 && !u.Oldest.IsZero() {
+					m.status += fmt.Sprintf(" (%s to %s)", u.Oldest.Format("2006-01-02"), u.Newest.Format("2006-01-02"))
+				}
+				break
+			}
 			m.status = fmt.Sprintf(
-				"Purge completed. Deleted: %d, Failed: %d, Throttled: %d",
-				u.Deleted, u.Failed, u.Throttled)
+				"Purge %s. Deleted: %d, Failed: %d, Throttled: %d",
+				verb, u.Deleted, u.Failed, u.Throttled)
+			if u.GuildID != "" || len(m.Jobs) > 0 {
+				m.status += fmt.Sprintf(" in %d channels", u.Channels)
+			}
 		}
 
+		if m.msgChan == nil {
+			return m, nil
+		}
 		return m, m.waitForMsg()
 	}
 
 	return m, nil
 }
 
+// setup configures purger from the options, then starts the purge or asks about a checkpoint first.
+func (m *PurgeModel) setup(purger *purge.Purger) (tea.Model, tea.Cmd) {
+	if err := purger.SetFilterExpr(m.Filter); err != nil {
+		m.err = err
+		m.done = true
+		return m, nil
+	}
+
+	if m.SearchDelay > 0 {
+		purger.SetSearchDelay(m.SearchDelay)
+	}
+
+	if m.DeleteDelay > 0 {
+		purger.SetDeleteDelay(m.DeleteDelay)
+	}
+
+	if m.MaxAttempts > 0 {
+		purger.SetMaxAttempts(m.MaxAttempts)
+	}
+
+	purger.SetDateRange(m.After, m.Before)
+	purger.SetDryRun(m.DryRun)
+	purger.SetSearch(m.Search)
+	m.purger = purger
+
+	if m.Report {
+		dir, err := archive.DefaultDir()
+		if err == nil {
+			m.reportPath = archive.Path(dir, m.archiveName(), archive.CSV)
+			var r *archive.CSVWriter
+			if r, err = archive.OpenCSV(m.reportPath); err == nil {
+				m.recorder = r
+				purger.SetReport(r)
+			}
+		}
+		if err != nil {
+			m.err = fmt.Errorf("opening report: %w", err)
+			m.done = true
+			return m, nil
+		}
+	}
+
+	// A dry run deletes nothing, so there is nothing to archive.
+	if !m.DryRun {
+		if err := m.openArchive(); err != nil {
+			m.err = fmt.Errorf("opening archive: %w", err)
+			m.done = true
+			return m, nil
+		}
+	}
+
+	// Progress saving is best effort, a purge still runs without a config dir.
+	if store, err := purge.DefaultCheckpointStore(); err == nil && !m.DryRun {
+		purger.SetCheckpointStore(store)
+		// Server and queued purges resume each channel's checkpoint by themselves.
+		if m.GuildID != "" || len(m.Jobs) > 0 {
+			return m, m.start()
+		}
+		// One saved with other settings is simply replaced as the purge goes.
+		if cp, err := purger.LoadCheckpoint(m.dmid); err != nil {
+			m.status = truncate(err.Error(), 60)
+		} else if purger.CanResume(cp) {
+			m.pending = cp
+			m.status = fmt.Sprintf("Checkpoint found (%d deleted). Resume? [y/n]", cp.Deleted)
+			return m, nil
+		}
+	}
+
+	return m, m.start()
+}
+
 // Very ugly view, will make the TUI look better in future.
 func (m *PurgeModel) View() string {
 	labelStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#FF0AFF")).Bold(true)
 	valueStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#BA55D3"))
 	errStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#FF3333")).Bold(true)
 
+	countLabel := "Deleted:"
+	if m.DryRun {
+		countLabel = "Matched:"
+	}
+
 	lines := []string{
-		fmt.Sprintf("%s %s", labelStyle.Render("Deleted:"), valueStyle.Render(fmt.Sprintf("%d", m.deletedCount))),
+		fmt.Sprintf("%s %s", labelStyle.Render(countLabel), valueStyle.Render(fmt.Sprintf("%d", m.deletedCount))),
 		fmt.Sprintf("%s %s", labelStyle.Render("Failed:"), valueStyle.Render(fmt.Sprintf("%d", m.failedCount))),
 		fmt.Sprintf("%s %s", labelStyle.Render("Last Msg:"), valueStyle.Render(truncate(m.lastDeleted, 40))),
 		fmt.Sprintf("%s %s", labelStyle.Render("Delete Delay:"), valueStyle.Render(m.DeleteDelay.String())),
 		fmt.Sprintf("%s %s", labelStyle.Render("Search Delay:"), valueStyle.Render(m.SearchDelay.String())),
 		fmt.Sprintf("%s %s", labelStyle.Render("Timeout:"), valueStyle.Render(m.timeout.String())),
-		fmt.Sprintf("%s %s", labelStyle.Render("Status:"), valueStyle.Render(truncate(m.status, 60))),
+		fmt.Sprintf("%s %s", labelStyle.Render("Status:"), valueStyle.Render(truncate(m.status, 100))),
+	}
+
+	if m.channel != "" {
+		lines = append([]string{
+			fmt.Sprintf("%s %s", labelStyle.Render("Channel:"), valueStyle.Render(truncate(m.channel, 40))),
+			fmt.Sprintf("%s %s", labelStyle.Render("In Channel:"), valueStyle.Render(fmt.Sprintf("%d %s, %d failed", m.channelDeleted, strings.ToLower(strings.TrimSuffix(countLabel, ":")), m.channelFailed))),
+			fmt.Sprintf("%s %s", labelStyle.Render("Channels Done:"), valueStyle.Render(fmt.Sprintf("%d/%d", m.channelsDone, m.channelTotal))),
+			"",
+		}, lines...)
+	}
+
+	if m.archivePath != "" {
+		lines = append(lines, fmt.Sprintf("%s %s", labelStyle.Render("Archive:"), valueStyle.Render(m.archivePath)))
+	}
+	if m.reportPath != "" {
+		lines = append(lines, fmt.Sprintf("%s %s", labelStyle.Render("Report:"), valueStyle.Render(m.reportPath)))
 	}
 
 	if m.done {
 		lines = append(lines, labelStyle.Render("[Enter] to quit"))
+	} else if m.msgChan != nil {
+		lines = append(lines, labelStyle.Render("[P] to pause/resume   [Esc] to stop"))
 	}
 
 	if m.err != nil {
@@ -0,0 +1,82 @@
+package tui
+
+import (
+	"testing"
+
+	"github.com/charmbracelet/bubbles/cursor"
+	tea "github.com/charmbracelet/bubbletea"
+
+	"purge/internal/purge"
+)
+
+// stray are messages the purge screen gets that aren't purge updates.
+var stray = []tea.Msg{cursor.Blink(), tea.MouseMsg{}, struct{}{}}
+
+func quits(cmd tea.Cmd) bool {
+	if cmd == nil {
+		return false
+	}
+	_, ok := cmd().(tea.QuitMsg)
+	return ok
+}
+
+func TestPurgeModelIgnoresStrayMessagesWhileConnecting(t *testing.T) {
+	t.Parallel()
+	m := NewPurgeModel("10", nil)
+	m.connecting = true
+
+	for _, msg := range stray {
+		if _, cmd := m.Update(msg); cmd != nil {
+			t.Errorf("%T while connecting returned a command", msg)
+		}
+	}
+	if _, cmd := m.Update(purge.UpdateInfo{Message: "late"}); quits(cmd) {
+		t.Error("an update while connecting quit the program")
+	}
+	if !m.connecting {
+		t.Error("no longer connecting")
+	}
+}
+
+func TestPurgeModelIgnoresStrayMessagesAtThePrompt(t *testing.T) {
+	t.Parallel()
+	m := NewPurgeModel("10", nil)
+	m.pending = &purge.Checkpoint{ChannelID: "10", Deleted: 3}
+
+	for _, msg := range stray {
+		if _, cmd := m.Update(msg); cmd != nil {
+			t.Errorf("%T at the resume prompt returned a command", msg)
+		}
+	}
+	if _, cmd := m.Update(purge.UpdateInfo{Message: "late"}); quits(cmd) {
+		t.Error("an update at the resume prompt quit the program")
+	}
+	if m.pending == nil {
+		t.Error("the resume prompt was dismissed")
+	}
+}
+
+func TestPurgeModelReadsOnePerUpdate(t *testing.T) {
+	t.Parallel()
+	m := NewPurgeModel("10", nil)
+	m.msgChan = make(chan tea.Msg, 1)
+
+	// A stray message mustn't start a second reader on msgChan.
+	for _, msg := range stray {
+		if _, cmd := m.Update(msg); cmd != nil {
+			t.Errorf("%T during a purge returned a command", msg)
+		}
+	}
+
+	_, cmd := m.Update(purge.UpdateDeleted{Content: "hi"})
+	if cmd == nil {
+		t.Fatal("no command to wait for the next update")
+	}
+	m.msgChan <- purge.UpdateInfo{Message: "next"}
+	if got := cmd(); got != (purge.UpdateInfo{Message: "next"}) {
+		t.Errorf("next message = %#v", got)
+	}
+	if m.deletedCount != 1 {
+		t.Errorf("deleted = %d, want 1", m.deletedCount)
+	}
+}
@@ -2,11 +2,16 @@ package tui
 
 import (
 	"fmt"
+	"maps"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
 
+	"purge/internal/archive"
+	"purge/internal/config"
 	"purge/internal/discord"
+	"purge/internal/purge"
 
 	"github.com/charmbracelet/bubbles/textinput"
 	tea "github.com/charmbracelet/bubbletea"
@@ -20,18 +25,38 @@ type SettingsModel struct {
 	filters  textinput.Model
 	searchMs textinput.Model
 	deleteMs textinput.Model
+	after    textinput.Model
+	before   textinput.Model
+	maxMB    textinput.Model
+	dryRun   bool
+	archive  bool
+	download bool
+	report   bool
+	search   bool
+
+	maxAttempts int               // From the config file, there is no field for it.
+	guildID     string            // Set for a purge of a whole server instead of one channel.
+	names       map[string]string // Channel names picked in the selector, by ID.
+
+	// Presets from the config file, cycled through with Space on the last row.
+	filter      string // The config file's filter, for presets without one.
+	presets     map[string]config.Preset
+	presetNames []string // Sorted.
+	preset      int      // Index into presetNames plus one, 0 for no preset.
+	ownChannel  string   // The channel field before a preset filled it in.
 
 	cursor        int
 	width, height int
+	err           error
 }
 
 func NewSettingsModel(client *discord.Client) *SettingsModel {
 
 	ch := textinput.New()
-	ch.Placeholder = "Channel ID / DM ID"
+	ch.Placeholder = "Channel ID / DM ID, comma separated for several"
 
 	f := textinput.New()
-	f.Placeholder = "word1,word2,word3"
+	f.Placeholder = `word1,word2 or expr: has:attachment AND NOT "keep"`
 
 	sd := textinput.New()
 	sd.Placeholder = "3000"
@@ -39,14 +64,86 @@ func NewSettingsModel(client *discord.Client) *SettingsModel {
 	dd := textinput.New()
 	dd.Placeholder = "2000"
 
+	af := textinput.New()
+	af.Placeholder = "2024-01-01 or 90d (empty = no limit)"
+
+	bf := textinput.New()
+	bf.Placeholder = "2024-06-30 or 30d (empty = no limit)"
+
+	mb := textinput.New()
+	mb.Placeholder = "25 (empty = no limit)"
+
 	ch.Focus()
 
-	return &SettingsModel{
+	m := &SettingsModel{
 		client:   client,
 		channel:  ch,
 		filters:  f,
 		searchMs: sd,
 		deleteMs: dd,
+		after:    af,
+		before:   bf,
+		maxMB:    mb,
+	}
+
+	// A broken config file is shown as an error, the form still works with the defaults.
+	cfg, err := config.Load()
+	if err != nil {
+		m.err = fmt.Errorf("config: %w", err)
+		cfg = config.Default()
+	}
+	m.applyConfig(cfg)
+
+	return m
+}
+
+// applyConfig pre-fills the form from the config file.
+func (m *SettingsModel) applyConfig(cfg *config.Config) {
+	m.filters.SetValue(cfg.Filter)
+	m.searchMs.SetValue(strconv.FormatInt(time.Duration(cfg.SearchDelay).Milliseconds(), 10))
+	m.deleteMs.SetValue(strconv.FormatInt(time.Duration(cfg.DeleteDelay).Milliseconds(), 10))
+	m.after.SetValue(cfg.After)
+	m.before.SetValue(cfg.Before)
+	if cfg.Archive.MaxAttachmentMB > 0 {
+		m.maxMB.SetValue(strconv.FormatFloat(cfg.Archive.MaxAttachmentMB, 'f', -1, 64))
+	}
+	m.archive = cfg.Archive.Messages
+	m.download = cfg.Archive.Attachments
+	m.report = cfg.Archive.Report
+	m.search = cfg.Search
+	m.maxAttempts = cfg.MaxAttempts
+	m.filter = cfg.Filter
+	m.presets = cfg.Presets
+	m.presetNames = slices.Sorted(maps.Keys(cfg.Presets))
+}
+
+// lastRow is the cursor of the bottom row: the preset choice, if there are presets to
+// choose from, or the search toggle.
+func (m *SettingsModel) lastRow() int {
+	if len(m.presetNames) > 0 && m.guildID == "" {
+		return 12
+	}
+	return 11
+}
+
+// nextPreset selects the next preset from the config file, filling in its channels and
+// filter. After the last one it goes back to no preset and the channels typed before.
+func (m *SettingsModel) nextPreset() {
+	if m.preset == 0 {
+		m.ownChannel = m.channel.Value()
+	}
+	m.preset = (m.preset + 1) % (len(m.presetNames) + 1)
+	if m.preset == 0 {
+		m.channel.SetValue(m.ownChannel)
+		m.filters.SetValue(m.filter)
+		return
+	}
+	p := m.presets[m.presetNames[m.preset-1]]
+	m.channel.SetValue(strings.Join(p.Channels, ","))
+	if p.Filter != "" {
+		m.filters.SetValue(p.Filter)
+	} else {
+		m.filters.SetValue(m.filter)
 	}
 }
 
@@ -54,6 +151,23 @@ func (m *SettingsModel) SetChannelID(id string) {
 	m.channel.SetValue(id)
 }
 
+// SetJobs fills in several channels, which are purged one after another.
+func (m *SettingsModel) SetJobs(jobs []purge.Job) {
+	m.names = make(map[string]string, len(jobs))
+	ids := make([]string, len(jobs))
+	for i, j := range jobs {
+		ids[i] = j.ChannelID
+		m.names[j.ChannelID] = j.Name
+	}
+	m.channel.SetValue(strings.Join(ids, ","))
+}
+
+// SetGuild makes the purge cover every channel and thread of g.
+func (m *SettingsModel) SetGuild(g discord.Guild) {
+	m.guildID = g.ID
+	m.channel.SetValue(g.ID)
+}
+
 func (m *SettingsModel) Init() tea.Cmd {
 	return textinput.Blink
 }
@@ -63,6 +177,9 @@ func (m *SettingsModel) updateFocus() {
 	m.filters.Blur()
 	m.searchMs.Blur()
 	m.deleteMs.Blur()
+	m.after.Blur()
+	m.before.Blur()
+	m.maxMB.Blur()
 
 	switch m.cursor {
 	case 0:
@@ -73,6 +190,12 @@ func (m *SettingsModel) updateFocus() {
 		m.searchMs.Focus()
 	case 3:
 		m.deleteMs.Focus()
+	case 4:
+		m.after.Focus()
+	case 5:
+		m.before.Focus()
+	case 6:
+		m.maxMB.Focus()
 	}
 }
 
@@ -92,11 +215,35 @@ func (m *SettingsModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.updateFocus()
 
 		case tea.KeyDown:
-			if m.cursor < 3 {
+			if m.cursor < m.lastRow() {
 				m.cursor++
 			}
 			m.updateFocus()
 
+		case tea.KeySpace:
+			// Cursors 7 to 12 are toggles and the preset choice, they have no text input
+			// to type into.
+			switch m.cursor {
+			case 7:
+				m.dryRun = !m.dryRun
+				return m, nil
+			case 8:
+				m.archive = !m.archive
+				return m, nil
+			case 9:
+				m.download = !m.download
+				return m, nil
+			case 10:
+				m.report = !m.report
+				return m, nil
+			case 11:
+				m.search = !m.search
+				return m, nil
+			case 12:
+				m.nextPreset()
+				return m, nil
+			}
+
 		case tea.KeyEnter:
 			return m.buildPurgeModel()
 
@@ -105,13 +252,16 @@ func (m *SettingsModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		}
 	}
 
-	var cmd1, cmd2, cmd3, cmd4 tea.Cmd
+	var cmd1, cmd2, cmd3, cmd4, cmd5, cmd6, cmd7 tea.Cmd
 	m.channel, cmd1 = m.channel.Update(msg)
 	m.filters, cmd2 = m.filters.Update(msg)
 	m.searchMs, cmd3 = m.searchMs.Update(msg)
 	m.deleteMs, cmd4 = m.deleteMs.Update(msg)
+	m.after, cmd5 = m.after.Update(msg)
+	m.before, cmd6 = m.before.Update(msg)
+	m.maxMB, cmd7 = m.maxMB.Update(msg)
 
-	return m, tea.Batch(cmd1, cmd2, cmd3, cmd4)
+	return m, tea.Batch(cmd1, cmd2, cmd3, cmd4, cmd5, cmd6, cmd7)
 }
 
 func (m *SettingsModel) buildPurgeModel() (tea.Model, tea.Cmd) {
@@ -120,16 +270,58 @@ func (m *SettingsModel) buildPurgeModel() (tea.Model, tea.Cmd) {
 		dmid = "0"
 	}
 
-	filters := []string{}
+	now := time.Now()
+	after, err := purge.ParseDateBound(m.after.Value(), now, false)
+	if err != nil {
+		m.err = err
+		return m, nil
+	}
+	// A date typed as the upper bound includes that whole day.
+	before, err := purge.ParseDateBound(m.before.Value(), now, true)
+	if err != nil {
+		m.err = err
+		return m, nil
+	}
+
+	filter := strings.TrimSpace(m.filters.Value())
+	if _, err := purge.ParseFilter(filter); err != nil {
+		m.err = fmt.Errorf("filter: %w", err)
+		return m, nil
+	}
+
+	var maxBytes int64
+	if v := strings.TrimSpace(m.maxMB.Value()); v != "" {
+		mb, err := strconv.ParseFloat(v, 64)
+		if err != nil || mb < 0 {
+			m.err = fmt.Errorf("invalid attachment size cap %q", v)
+			return m, nil
+		}
+		maxBytes = int64(mb * 1024 * 1024)
+	}
 
-	filtersValue := strings.TrimSpace(m.filters.Value())
-	if filtersValue != "" {
-		filters = strings.Split(filtersValue, ",")
-		for i, f := range filters {
-			filters[i] = strings.TrimSpace(f)
+	var jobs []purge.Job
+	if m.guildID == "" && strings.Contains(dmid, ",") {
+		for _, id := range strings.Split(dmid, ",") {
+			if id = strings.TrimSpace(id); id != "" {
+				jobs = append(jobs, purge.Job{ChannelID: id, Name: m.names[id]})
+			}
+		}
+		if len(jobs) == 0 {
+			m.err = fmt.Errorf("no channel IDs in %q", dmid)
+			return m, nil
 		}
+		dmid = jobs[0].ChannelID
 	}
+
 	pm := NewPurgeModel(dmid, m.client)
+	if m.guildID != "" {
+		pm.GuildID = dmid
+		pm.ArchiveName = archive.GuildName(dmid)
+	}
+	if len(jobs) > 1 {
+		pm.Jobs = jobs
+		pm.ArchiveName = archive.QueueName(now)
+	}
 
 	searchMsValue := m.searchMs.Value()
 	searchMsInt, _ := strconv.Atoi(searchMsValue)
@@ -137,9 +329,18 @@ func (m *SettingsModel) buildPurgeModel() (tea.Model, tea.Cmd) {
 	deleteMsValue := m.deleteMs.Value()
 	deleteMsInt, _ := strconv.Atoi(deleteMsValue)
 
-	pm.Filters = filters
+	pm.Filter = filter
 	pm.SearchDelay = time.Millisecond * time.Duration(searchMsInt)
 	pm.DeleteDelay = time.Millisecond * time.Duration(deleteMsInt)
+	pm.MaxAttempts = m.maxAttempts
+	pm.DryRun = m.dryRun
+	pm.Archive = m.archive
+	pm.DownloadAttachments = m.download
+	pm.Report = m.report
+	pm.Search = m.search
+	pm.MaxAttachmentSize = maxBytes
+	pm.After = after
+	pm.Before = before
 
 	return pm, func() tea.Msg {
 		return tea.KeyMsg{Type: tea.KeyEnter}
@@ -155,21 +356,72 @@ func (m *SettingsModel) View() string {
 
 	pinkStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#FF0AFF")).Bold(true)
 
+	toggle := func(on bool, cursor int) string {
+		box := "[ ]"
+		if on {
+			box = "[x]"
+		}
+		if m.cursor == cursor {
+			return pinkStyle.Render("> " + box)
+		}
+		return box
+	}
+
+	target := "Channel ID:"
+	if m.guildID != "" {
+		target = "Server ID (every channel and thread):"
+	}
+
+	var presetRow string
+	if m.lastRow() == 12 {
+		name := "none"
+		if m.preset > 0 {
+			name = m.presetNames[m.preset-1]
+		}
+		if m.cursor == 12 {
+			name = pinkStyle.Render("> " + name)
+		}
+		presetRow = "Preset from the config file ([Space] for the next):\n" + name + "\n\n"
+	}
+
 	content := fmt.Sprintf(
 		"Purge Settings\n\n"+
-			"Channel ID:\n%s\n\n"+
-			"Filters (comma-separated):\n%s\n\n"+
+			"%s\n%s\n\n"+
+			"Filter (words, comma-separated, or expr: and an expression):\n%s\n\n"+
 			"Search Delay (ms):\n%s\n\n"+
 			"Delete Delay (ms):\n%s\n\n"+
-			"%s Start Purge   %s Quit",
+			"Sent on or after:\n%s\n\n"+
+			"Sent on or before:\n%s\n\n"+
+			"Attachment size cap (MB):\n%s\n\n"+
+			"Dry run, only list matches ([Space]):\n%s\n\n"+
+			"Archive messages before deleting ([Space]):\n%s\n\n"+
+			"Download attachments before deleting ([Space]):\n%s\n\n"+
+			"Write a CSV report of matched messages ([Space]):\n%s\n\n"+
+			"Find messages with Discord search, faster ([Space]):\n%s\n\n"+
+			"%s%s Start Purge   %s Quit",
+		target,
 		m.channel.View(),
 		m.filters.View(),
 		m.searchMs.View(),
 		m.deleteMs.View(),
+		m.after.View(),
+		m.before.View(),
+		m.maxMB.View(),
+		toggle(m.dryRun, 7),
+		toggle(m.archive, 8),
+		toggle(m.download, 9),
+		toggle(m.report, 10),
+		toggle(m.search, 11),
+		presetRow,
 		pinkStyle.Render("[Enter]"),
 		pinkStyle.Render("[Esc]"),
 	)
 
+	if m.err != nil {
+		errStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#FF3333")).Bold(true)
+		content += "\n\n" + errStyle.Render("Error: "+m.err.Error())
+	}
+
 	return lipgloss.Place(
 		m.width, m.height,
 		lipgloss.Center, lipgloss.Center,